@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Define an alias: when the first word of a command line is name, it's replaced with value
+func (cmd *Cmd) SetAlias(name, value string) {
+	cmd.aliasesLock.Lock()
+	defer cmd.aliasesLock.Unlock()
+
+	if cmd.aliases == nil {
+		cmd.aliases = make(map[string]string)
+	}
+
+	cmd.aliases[name] = value
+}
+
+// Returns the value of an alias and true if it's defined
+func (cmd *Cmd) GetAlias(name string) (string, bool) {
+	cmd.aliasesLock.Lock()
+	defer cmd.aliasesLock.Unlock()
+
+	value, ok := cmd.aliases[name]
+	return value, ok
+}
+
+// Remove an alias
+func (cmd *Cmd) RemoveAlias(name string) {
+	cmd.aliasesLock.Lock()
+	defer cmd.aliasesLock.Unlock()
+
+	delete(cmd.aliases, name)
+}
+
+// Returns the alias names, sorted
+func (cmd *Cmd) aliasNames() []string {
+	cmd.aliasesLock.Lock()
+	defer cmd.aliasesLock.Unlock()
+
+	names := make([]string, 0, len(cmd.aliases))
+	for name := range cmd.aliases {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+//
+// Replace the first word of line, if it's an alias, with the alias value.
+// The expansion is repeated as long as the first word is an alias, unless
+// the alias refers to itself (i.e. alias ls="ls -l"), in which case the command is used.
+//
+func (cmd *Cmd) expandAliases(line string) (string, error) {
+	var seen []string
+
+	for {
+		word, rest := nextWord(line)
+
+		value, ok := cmd.GetAlias(word)
+		if !ok {
+			return line, nil
+		}
+
+		for _, name := range seen {
+			if name == word {
+				return "", fmt.Errorf("recursive alias: %s", strings.Join(append(seen, word), " -> "))
+			}
+		}
+
+		seen = append(seen, word)
+		line = strings.TrimSpace(value + " " + rest)
+
+		if next, _ := nextWord(line); next == word {
+			// the alias refers to the command with the same name
+			return line, nil
+		}
+	}
+}
+
+//
+// The aliases are saved to a file next to the history file
+//
+func (cmd *Cmd) aliasFile() string {
+	if len(cmd.HistoryFile) == 0 {
+		return ""
+	}
+
+	return cmd.HistoryFile + ".aliases"
+}
+
+func (cmd *Cmd) readAliasFile() {
+	filename := cmd.aliasFile()
+	if len(filename) == 0 {
+		return
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(cmd.Stderr, err)
+		}
+
+		return
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if parts := strings.SplitN(scanner.Text(), "=", 2); len(parts) == 2 {
+			cmd.SetAlias(parts[0], parts[1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(cmd.Stderr, err)
+	}
+}
+
+func (cmd *Cmd) writeAliasFile() {
+	filename := cmd.aliasFile()
+	if len(filename) == 0 {
+		return
+	}
+
+	names := cmd.aliasNames()
+	if len(names) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintln(cmd.Stderr, "Error removing alias file:", err)
+		}
+
+		return
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		fmt.Fprintln(cmd.Stderr, "Error writing alias file:", err)
+		return
+	}
+
+	for _, name := range names {
+		value, _ := cmd.GetAlias(name)
+		fmt.Fprintf(f, "%s=%s\n", name, value)
+	}
+
+	f.Close()
+}
+
+func (cmd *Cmd) addAliasCommands() {
+	cmd.Add(NewCommand("alias",
+		SetHelp(`alias name="command args": define an alias, or list the aliases`),
+		SetArgs("[name]", "[value...]"),
+		SetCmdE(cmd.AliasCommand)))
+
+	cmd.Add(NewCommand("unalias",
+		SetHelp(`remove the specified aliases`),
+		SetArgs("name..."),
+		SetCmdE(cmd.UnaliasCommand)))
+}
+
+//
+// Alias command: define an alias as "alias name=value" or "alias name value",
+// display an alias as "alias name" or list all aliases
+//
+func (cmd *Cmd) AliasCommand(command *Command, line string) error {
+	args := command.Args()
+
+	if len(args) == 0 {
+		for _, name := range cmd.aliasNames() {
+			value, _ := cmd.GetAlias(name)
+			fmt.Fprintf(command.Stdout(), "%s=%q\n", name, value)
+		}
+
+		return nil
+	}
+
+	name, value := args[0], strings.Join(args[1:], " ")
+
+	if i := strings.Index(name, "="); i >= 0 && len(args) == 1 {
+		name, value = name[:i], name[i+1:]
+	} else if len(args) == 1 {
+		value, ok := cmd.GetAlias(name)
+		if !ok {
+			return fmt.Errorf("no such alias: %s", name)
+		}
+
+		fmt.Fprintf(command.Stdout(), "%s=%q\n", name, value)
+		return nil
+	}
+
+	if len(name) == 0 || strings.IndexFunc(name, func(ch rune) bool { return strings.ContainsRune(" \t|>\"'", ch) }) >= 0 {
+		return fmt.Errorf("invalid alias name: %s", name)
+	}
+
+	if len(strings.TrimSpace(value)) == 0 {
+		return fmt.Errorf("empty alias: %s", name)
+	}
+
+	cmd.SetAlias(name, value)
+
+	if _, err := cmd.expandAliases(name); err != nil {
+		cmd.RemoveAlias(name)
+		return err
+	}
+
+	return nil
+}
+
+//
+// Unalias command: remove the specified aliases
+//
+func (cmd *Cmd) UnaliasCommand(command *Command, line string) error {
+	for _, name := range command.Args() {
+		if _, ok := cmd.GetAlias(name); !ok {
+			return fmt.Errorf("no such alias: %s", name)
+		}
+
+		cmd.RemoveAlias(name)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestExpandAliases(t *testing.T) {
+	commander := &Cmd{}
+
+	commander.SetAlias("ll", "ls -l")
+	commander.SetAlias("ls", "ls --color")
+	commander.SetAlias("la", "ll -a")
+	commander.SetAlias("loop1", "loop2 x")
+	commander.SetAlias("loop2", "loop1 y")
+
+	tests := []struct {
+		line string
+		want string
+		err  bool
+	}{
+		{"cat file", "cat file", false},
+		{"ls", "ls --color", false},
+		{"ll foo", "ls --color -l foo", false},
+		{"la", "ls --color -l -a", false},
+		{"echo ll", "echo ll", false},
+		{"loop1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := commander.expandAliases(tt.line)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("expandAliases(%q) = %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+	}
+}
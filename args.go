@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+//
+// A positional argument declaration
+//
+type argSpec struct {
+	name     string
+	optional bool
+	variadic bool
+}
+
+func (spec argSpec) String() string {
+	s := spec.name
+	if spec.variadic {
+		s += "..."
+	}
+	if spec.optional {
+		s = "[" + s + "]"
+	}
+
+	return s
+}
+
+//
+// Declare the positional arguments of the command.
+//
+// Each argument is a name, optionally followed by "..." if it accepts one or more values
+// (only allowed for the last argument), and optionally enclosed in brackets
+// if it can be omitted (only allowed after the required arguments), i.e.:
+//
+//	SetArgs("src", "dst...")
+//	SetArgs("name", "[value]")
+//	SetArgs("[files...]")
+//
+// The arguments are validated before calling the command and are available
+// from Command.Args and Command.Arg.
+//
+func SetArgs(args ...string) Option {
+	return func(command *Command) {
+		command.argSpecs = nil
+
+		for i, arg := range args {
+			spec := argSpec{name: arg}
+
+			if strings.HasPrefix(spec.name, "[") && strings.HasSuffix(spec.name, "]") {
+				spec.name = spec.name[1 : len(spec.name)-1]
+				spec.optional = true
+			}
+
+			if strings.HasSuffix(spec.name, "...") {
+				spec.name = strings.TrimSuffix(spec.name, "...")
+				spec.variadic = true
+			}
+
+			switch {
+			case len(spec.name) == 0:
+				panic(fmt.Sprintf("%s: invalid argument declaration %q", command.name, arg))
+			case spec.variadic && i < len(args)-1:
+				panic(fmt.Sprintf("%s: variadic argument %q must be the last one", command.name, arg))
+			case !spec.optional && i > 0 && command.argSpecs[i-1].optional:
+				panic(fmt.Sprintf("%s: required argument %q follows an optional one", command.name, arg))
+			}
+
+			command.argSpecs = append(command.argSpecs, spec)
+		}
+	}
+}
+
+// validate the number of positional arguments against the declarations
+func (command *Command) parseArgs(args []string) error {
+	command.args = args
+
+	if command.argSpecs == nil {
+		return nil
+	}
+
+	required := 0
+	variadic := false
+
+	for _, spec := range command.argSpecs {
+		if !spec.optional {
+			required++
+		}
+
+		variadic = spec.variadic
+	}
+
+	if len(args) < required {
+		return fmt.Errorf("missing argument: %s", command.argSpecs[len(args)].name)
+	}
+
+	if !variadic && len(args) > len(command.argSpecs) {
+		return fmt.Errorf("too many arguments: %s", strings.Join(args[len(command.argSpecs):], " "))
+	}
+
+	return nil
+}
+
+// the positional arguments declarations, as displayed in the usage
+func (command *Command) argsUsage() string {
+	var s string
+
+	for _, spec := range command.argSpecs {
+		s += " " + spec.String()
+	}
+
+	return s
+}
+
+// Returns the positional arguments (the arguments following the flags)
+func (command *Command) Args() []string {
+	return command.args
+}
+
+//
+// Returns the value of the named positional argument, or an empty string if it wasn't set.
+// The values of a variadic argument are joined with a space (use Args to get them separately).
+//
+func (command *Command) Arg(name string) string {
+	for i, spec := range command.argSpecs {
+		if spec.name != name {
+			continue
+		}
+
+		if i >= len(command.args) {
+			return ""
+		}
+
+		if spec.variadic {
+			return strings.Join(command.args[i:], " ")
+		}
+
+		return command.args[i]
+	}
+
+	return ""
+}
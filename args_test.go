@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSetArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		line  string
+		want  string // the Arg values, or the error
+		usage string
+	}{
+		{nil, "a b", "[a b]", ""},
+		{[]string{"src", "dst"}, "a b", "src=a dst=b", " src dst"},
+		{[]string{"src", "dst"}, "a", "missing argument: dst", " src dst"},
+		{[]string{"src", "dst"}, "", "missing argument: src", " src dst"},
+		{[]string{"src", "dst"}, "a b c", "too many arguments: c", " src dst"},
+		{[]string{"name", "[value]"}, "a", "name=a value=", " name [value]"},
+		{[]string{"name", "[value]"}, "a b", "name=a value=b", " name [value]"},
+		{[]string{"src", "dst..."}, "a b c", "src=a dst=b c", " src dst..."},
+		{[]string{"src", "dst..."}, "a", "missing argument: dst", " src dst..."},
+		{[]string{"[files...]"}, "", "files=", " [files...]"},
+		{[]string{"[files...]"}, "a b", "files=a b", " [files...]"},
+	}
+
+	for _, tt := range tests {
+		var got string
+
+		command := NewCommand("test", SetArgs(tt.args...), SetCmdE(func(command *Command, line string) error {
+			if tt.args == nil {
+				got = fmt.Sprint(command.Args())
+				return nil
+			}
+
+			var values []string
+			for _, spec := range command.argSpecs {
+				values = append(values, spec.name+"="+command.Arg(spec.name))
+			}
+
+			got = strings.Join(values, " ")
+			return nil
+		}))
+
+		commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+		commander.Init()
+		commander.Add(command)
+
+		commander.OneCmd(strings.TrimSpace("test " + tt.line))
+
+		if err := commander.LastError(); err != nil {
+			got = err.Error()
+		}
+
+		if got != tt.want || command.argsUsage() != tt.usage {
+			t.Errorf("%q %q: %q, usage %q, want %q, %q", tt.args, tt.line, got, command.argsUsage(), tt.want, tt.usage)
+		}
+	}
+}
+
+func TestSetArgsPanics(t *testing.T) {
+	tests := [][]string{
+		{""},
+		{"[]"},
+		{"files...", "dst"},
+		{"[src]", "dst"},
+	}
+
+	for _, args := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("SetArgs(%q): no panic", args)
+				}
+			}()
+
+			NewCommand("test", SetArgs(args...))
+		}()
+	}
+}
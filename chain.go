@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+)
+
+//
+// A command in a sequence, and the operator that precedes it
+//
+type chainItem struct {
+	op   string
+	line string
+}
+
+//
+// execute the line, that can be a sequence of commands separated by ";", "&&" and "||".
+// A command preceded by "&&" is executed only if the previous command succeeded,
+// and a command preceded by "||" only if it failed.
+//
+// The sequence terminates when a command returns stop, or when the context is cancelled.
+// Returns the result of the last command executed and its text
+// (the errors of the other commands in the sequence are reported here).
+//
+// In the foreground, the interpreter status ($?) is updated after each command in the sequence.
+// In the background, it's left unchanged and $? is the status of the previous command in the sequence.
+//
+func (cmd *Cmd) dispatch(ctx context.Context, s streams, line string, foreground bool) (stop bool, executed string, err error) {
+	return cmd.dispatchChain(ctx, s, line, true, foreground)
+}
+
+//
+// execute the sequence of commands, expanding the aliases if requested
+// (the commands in the value of an alias are not expanded again)
+//
+func (cmd *Cmd) dispatchChain(ctx context.Context, s streams, line string, aliases, foreground bool) (stop bool, executed string, err error) {
+	tokens, err := tokenize(line, nil)
+	if err != nil {
+		return false, line, err
+	}
+
+	items, err := splitChain(line, tokens)
+	if err != nil {
+		return false, line, err
+	}
+
+	// in the background, the status of the commands in the sequence (shared with the sequences in the aliases)
+	status, ok := ctx.Value(statusKey{}).(*int)
+	if !foreground && !ok {
+		initial := cmd.ExitStatus()
+		status = &initial
+		ctx = context.WithValue(ctx, statusKey{}, status)
+	}
+
+	// the last command executed
+	last := -1
+
+	for i, item := range items {
+		if (item.op == "&&" && err != nil) || (item.op == "||" && err == nil) {
+			continue
+		}
+
+		if last >= 0 {
+			// the previous command result is replaced by the result of this command
+			if foreground {
+				cmd.setStatus(err)
+			} else {
+				*status = errorStatus(err)
+			}
+
+			if err != nil {
+				cmd.reportError(s, items[last].line, err)
+			}
+		}
+
+		last = i
+		executed = item.line
+
+		if aliases {
+			expanded, aerr := cmd.expandAliases(item.line)
+			if aerr != nil {
+				err = aerr
+				continue
+			}
+
+			if expanded != item.line {
+				stop, _, err = cmd.dispatchChain(ctx, s, expanded, false, foreground)
+			} else {
+				stop, err = cmd.dispatchPipeline(ctx, s, item.line)
+			}
+		} else {
+			stop, err = cmd.dispatchPipeline(ctx, s, item.line)
+		}
+
+		if stop || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return
+}
+
+//
+// Split the tokenized line on the sequence operators (";", "&&" and "||").
+// Returns the text of each command in the sequence, with the operator that precedes it.
+// A trailing ";" is allowed.
+//
+func splitChain(line string, tokens []token) (items []chainItem, err error) {
+	start := 0
+	op := ""
+
+	for i, t := range tokens {
+		if t.op && (t.value == ";" || t.value == "&&" || t.value == "||") {
+			if i == start {
+				return nil, errors.New("syntax error: unexpected " + t.value)
+			}
+
+			items = append(items, chainItem{op: op, line: tokensText(line, tokens[start:i])})
+			start, op = i+1, t.value
+		}
+	}
+
+	if start < len(tokens) || len(items) == 0 {
+		items = append(items, chainItem{op: op, line: tokensText(line, tokens[start:])})
+	} else if op != ";" {
+		return nil, errors.New("syntax error: unexpected end of line after " + op)
+	}
+
+	return
+}
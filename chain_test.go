@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitChain(t *testing.T) {
+	tests := []struct {
+		line string
+		want []chainItem
+		err  string
+	}{
+		{"ls", []chainItem{{"", "ls"}}, ""},
+		{"", []chainItem{{"", ""}}, ""},
+		{"a 1; b 2 && c || d;", []chainItem{{"", "a 1"}, {";", "b 2"}, {"&&", "c"}, {"||", "d"}}, ""},
+		{`echo "a;b" 'c&&d' e\;f`, []chainItem{{"", `echo "a;b" 'c&&d' e\;f`}}, ""},
+		{"a | b > f; c", []chainItem{{"", "a | b > f"}, {";", "c"}}, ""},
+		{"; a", nil, "syntax error: unexpected ;"},
+		{"a && || b", nil, "syntax error: unexpected ||"},
+		{"a &&", nil, "syntax error: unexpected end of line after &&"},
+		{"a ||", nil, "syntax error: unexpected end of line after ||"},
+	}
+
+	for _, tt := range tests {
+		tokens, err := tokenize(tt.line, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items, err := splitChain(tt.line, tokens)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("splitChain(%q): error %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(items, tt.want) {
+			t.Errorf("splitChain(%q) = %q, %v, want %q", tt.line, items, err, tt.want)
+		}
+	}
+}
+
+func TestDispatchChain(t *testing.T) {
+	var out bytes.Buffer
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	commander.Init()
+
+	commander.Add(NewCommand("say", SetCmd(func(_ *Command, line string) bool {
+		out.WriteString(line + "\n")
+		return false
+	})))
+
+	commander.Add(NewCommand("fail", SetCmdE(func(*Command, string) error {
+		return errors.New("failed")
+	})))
+
+	var reported []string
+
+	commander.OnError = func(line string, err error) {
+		reported = append(reported, line)
+	}
+
+	tests := []struct {
+		line     string
+		out      string
+		reported []string
+		status   int
+	}{
+		{"say a; say b", "a\nb\n", nil, StatusOK},
+		{"say a && say b || say c", "a\nb\n", nil, StatusOK},
+		{"fail && say b || say c", "c\n", []string{"fail"}, StatusOK},
+		{"fail; say $?", "1\n", []string{"fail"}, StatusOK},
+		{"say a; fail", "a\n", []string{"fail"}, StatusError},
+		{"fail || fail 2 && say b", "", []string{"fail", "fail 2"}, StatusError},
+		{"say a;", "a\n", nil, StatusOK},
+		{"say a; &&", "", []string{"say a; &&"}, StatusError},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		reported = nil
+
+		commander.OneCmd(tt.line)
+
+		if out.String() != tt.out || !reflect.DeepEqual(reported, tt.reported) || commander.ExitStatus() != tt.status {
+			t.Errorf("%q: output %q, reported %q, status %d, want %q, %q, %d",
+				tt.line, out.String(), reported, commander.ExitStatus(), tt.out, tt.reported, tt.status)
+		}
+	}
+}
+
+func TestBackgroundStatus(t *testing.T) {
+	var out bytes.Buffer
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	commander.Init()
+
+	commander.Add(NewCommand("say", SetCmd(func(command *Command, line string) bool {
+		fmt.Fprintln(command.Stdout(), line)
+		return false
+	})))
+
+	commander.SetAlias("bg", "nope; say alias=$?")
+
+	tests := []struct {
+		line   string
+		output string
+	}{
+		{"say $?", "0\n"},
+		{"nope; say $?", "127\n"},
+		{"nope || say $?; say $?", "127\n0\n"},
+		{"bg; say $?", "alias=127\n0\n"},
+	}
+
+	for _, tt := range tests {
+		job := commander.StartJob(tt.line)
+		job.Wait()
+
+		// the commands errors are also in the job output
+		output := strings.Replace(job.Output(), "invalid command: nope\n", "", -1)
+
+		if output != tt.output || commander.ExitStatus() != StatusOK {
+			t.Errorf("%q: output %q, status %d, want %q, %d", tt.line, output, commander.ExitStatus(), tt.output, StatusOK)
+		}
+	}
+}
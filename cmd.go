@@ -16,48 +16,159 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/gobs/args"
-	"github.com/gobs/pretty"
 	"github.com/peterh/liner"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
-	"reflect"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
+	"text/template"
+	"time"
 	"unicode"
 )
 
+var (
+	// returned when a line doesn't match any of the registered commands
+	ErrUnknownCommand = errors.New("unknown command")
+
+	// can be returned by a command set with SetCmdE to terminate the interpreter
+	ErrStop = errors.New("stop")
+
+	// returned when a line ends within quotes
+	ErrUnterminatedQuote = errors.New("syntax error: unterminated quote")
+)
+
+//
+// This is returned when a command is called with invalid flags or arguments
+//
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+//
+// This is used to describe a new command.
 //
-// This is used to describe a new command
+// The command passed to the command function is a copy made for each invocation,
+// with its own flags, arguments and context, so that the same command can run concurrently.
 //
 type Command struct {
 	// command name
 	name string
-	// command alias
-	alias string
+	// command aliases
+	aliases []string
+	// the category the command is listed under, in help
+	category string
+	// hidden commands are not listed in help or offered for completion
+	hidden bool
+	// the deprecation message for the command and for its flags
+	deprecated      string
+	deprecatedFlags map[string]string
 	// command description
 	help string
 	// the function to call to execute the command
-	call func(*Command, string) bool
+	call func(*Command, string) (bool, error)
 	// list of possible sub commands
 	subCommands map[string]*Command
 	flags       *flag.FlagSet
+	// the flag definitions, to create a new flag set for each invocation
+	flagDefs []func(*flag.FlagSet)
+
+	// positional arguments declarations and values
+	argSpecs []argSpec
+	args     []string
+
+	// completion functions for the command arguments and flag values
+	completer      CompleterFunc
+	flagCompleters map[string]CompleterFunc
+
+	// the command this is a sub command of, if any
+	parent *Command
 
 	cmdline *Cmd
+
+	// the context of the running command
+	ctx context.Context
+	// the streams of the running command, if they are not the interpreter ones
+	streams streams
+	// the parameters of the running command as typed, before the variables are expanded
+	rawParams string
+}
+
+//
+// The input and output streams of a command
+//
+type streams struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
 }
 
 type Option func(command *Command)
 
-func SetCmdAlias(alias string) Option {
+//
+// Add one or more aliases to the command.
+// The command is still reachable by its name, and an alias never shadows the name of another command.
+//
+func SetCmdAlias(aliases ...string) Option {
+	return func(command *Command) {
+		command.aliases = append(command.aliases, aliases...)
+	}
+}
+
+// Set the category the command is listed under, in help
+func SetCategory(category string) Option {
 	return func(command *Command) {
-		command.alias = alias
+		command.category = category
+	}
+}
+
+// Hide the command from the help listing and from completion (it can still be executed)
+func SetHidden() Option {
+	return func(command *Command) {
+		command.hidden = true
+	}
+}
+
+//
+// Mark the command as deprecated: a warning with the message (i.e. "use X instead")
+// is displayed the first time the command is executed
+//
+func SetDeprecated(message string) Option {
+	return func(command *Command) {
+		command.deprecated = message
+	}
+}
+
+//
+// Mark the flag as deprecated: a warning with the message
+// is displayed the first time the flag is used
+//
+func SetDeprecatedFlag(name, message string) Option {
+	return func(command *Command) {
+		if command.deprecatedFlags == nil {
+			command.deprecatedFlags = make(map[string]string)
+		}
+
+		command.deprecatedFlags[name] = message
 	}
 }
 
@@ -67,21 +178,37 @@ func SetHelp(help string) Option {
 	}
 }
 
-func SetFlag(flag string, value string, help string) Option {
+func SetFlag(name string, value string, help string) Option {
 	return func(command *Command) {
-		command.flags.String(flag, value, help)
+		command.addFlag(func(f *flag.FlagSet) { f.String(name, value, help) })
 	}
 }
 
-func SetBoolFlag(flag string, value bool, help string) Option {
+func SetBoolFlag(name string, value bool, help string) Option {
 	return func(command *Command) {
-		command.flags.Bool(flag, value, help)
+		command.addFlag(func(f *flag.FlagSet) { f.Bool(name, value, help) })
 	}
 }
 
 func SetCmd(cmd func(command *Command, line string) (stop bool)) Option {
 	return func(command *Command) {
-		command.call = cmd
+		command.call = func(command *Command, line string) (bool, error) {
+			return cmd(command, line), nil
+		}
+	}
+}
+
+// Set a command function that can report a failure.
+// Return ErrStop (or an error wrapping it) to terminate the interpreter.
+func SetCmdE(cmd func(command *Command, line string) error) Option {
+	return func(command *Command) {
+		command.call = func(command *Command, line string) (bool, error) {
+			if err := cmd(command, line); !errors.Is(err, ErrStop) {
+				return false, err
+			}
+
+			return true, nil
+		}
 	}
 }
 
@@ -90,34 +217,74 @@ func NewCommand(name string, opts ...Option) *Command {
 	command := &Command{
 		name:        name,
 		help:        "",
-		call:        func(*Command, string) bool { return false },
-		subCommands: make(map[string]*Command),
-		flags:       flag.NewFlagSet(name, flag.ContinueOnError)}
+		call:        func(*Command, string) (bool, error) { return false, nil },
+		subCommands: make(map[string]*Command)}
+
+	command.flags = command.newFlagSet()
 
 	for _, opt := range opts {
 		opt(command)
 	}
 
-	command.flags.Usage = func() {
-		fmt.Fprintf(os.Stderr, "%s -%s", command.alias, command.help+"\n")
-		PrintDefaults(command.flags)
+	return command
+}
+
+// add a flag definition
+func (command *Command) addFlag(def func(*flag.FlagSet)) {
+	command.flagDefs = append(command.flagDefs, def)
+	def(command.flags)
+}
+
+// create a flag set with all the flag definitions
+func (command *Command) newFlagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(command.name, flag.ContinueOnError)
+
+	for _, def := range command.flagDefs {
+		def(flags)
 	}
 
-	if len(command.alias) == 0 {
-		command.alias = command.name
+	flags.Usage = func() {
+		command.printUsage(command.Stderr())
 	}
 
-	return command
+	return flags
+}
+
+// create a copy of the command to run, with its own flag set
+func (command *Command) newInvocation(cmd *Cmd, ctx context.Context, s streams) *Command {
+	invocation := *command
+	invocation.cmdline = cmd
+	invocation.ctx = ctx
+	invocation.streams = s
+	invocation.args = nil
+	invocation.flags = invocation.newFlagSet()
+
+	return &invocation
 }
 
 //Prints the default values of all defined flags in the set.
 func PrintDefaults(f *flag.FlagSet) {
+	FprintDefaults(os.Stdout, f)
+}
+
+//Prints the default values of all defined flags in the set to w.
+func FprintDefaults(w io.Writer, f *flag.FlagSet) {
 	f.VisitAll(func(flag *flag.Flag) {
-		if reflect.TypeOf(flag.Value).String() == "*flag.boolValue" {
-			fmt.Println(fmt.Sprintf("-%s %s", flag.Name, flag.Usage))
-		} else {
-			fmt.Println(fmt.Sprintf("-%s=%s %s", flag.Name, flag.DefValue, flag.Usage))
+		parts := []string{"-" + flag.Name}
+
+		if typ := flagType(flag); len(typ) > 0 {
+			parts = append(parts, typ)
+		}
+
+		if len(flag.Usage) > 0 {
+			parts = append(parts, flag.Usage)
+		}
+
+		if !isZeroDefault(flag) {
+			parts = append(parts, fmt.Sprintf("(default %s)", flag.DefValue))
 		}
+
+		fmt.Fprintln(w, strings.Join(parts, " "))
 	})
 }
 
@@ -125,24 +292,224 @@ func (command *Command) GetCmdline() *Cmd {
 	return command.cmdline
 }
 
-func (command *Command) AddSubCommand(name string, opts ...Option) {
+// Returns the input stream of the running command. By default this is the input stream
+// of the command interpreter the command belongs to, or os.Stdin if the command hasn't been added to one.
+func (command *Command) Stdin() io.Reader {
+	if command.streams.stdin != nil {
+		return command.streams.stdin
+	}
+
+	if command.cmdline != nil && command.cmdline.Stdin != nil {
+		return command.cmdline.Stdin
+	}
+
+	return os.Stdin
+}
+
+// Returns the output stream of the running command. By default this is the output stream
+// of the command interpreter the command belongs to, or os.Stdout if the command hasn't been added to one.
+func (command *Command) Stdout() io.Writer {
+	if command.streams.stdout != nil {
+		return command.streams.stdout
+	}
+
+	if command.cmdline != nil && command.cmdline.Stdout != nil {
+		return command.cmdline.Stdout
+	}
+
+	return os.Stdout
+}
+
+// Returns the error stream of the running command. By default this is the error stream
+// of the command interpreter the command belongs to, or os.Stderr if the command hasn't been added to one.
+func (command *Command) Stderr() io.Writer {
+	if command.streams.stderr != nil {
+		return command.streams.stderr
+	}
+
+	if command.cmdline != nil && command.cmdline.Stderr != nil {
+		return command.cmdline.Stderr
+	}
+
+	return os.Stderr
+}
+
+// Returns the context of the running command.
+// It is cancelled when the user presses Ctrl-C while the command is running in the foreground,
+// or when the command loop terminates for commands started with Go.
+func (command *Command) Context() context.Context {
+	if command.ctx != nil {
+		return command.ctx
+	}
+
+	return context.Background()
+}
+
+// attach the command and all its sub commands to the command interpreter
+func (command *Command) setCmdline(cmd *Cmd) {
+	command.cmdline = cmd
+
+	for _, subcommand := range command.subCommands {
+		subcommand.setCmdline(cmd)
+	}
+}
+
+//
+// Add a sub command to the command and return it, so that more sub commands can be added to it
+//
+func (command *Command) AddSubCommand(name string, opts ...Option) *Command {
 
 	subcommand := NewCommand(name, opts...)
+	subcommand.parent = command
+	subcommand.cmdline = command.cmdline
 
-	if len(subcommand.alias) > 0 && subcommand.alias != subcommand.name {
-		command.subCommands[subcommand.alias] = subcommand
-	} else {
-		command.subCommands[subcommand.name] = subcommand
+	registerCommand(command.subCommands, subcommand)
+	return subcommand
+}
+
+//
+// Register the command under its name and its aliases, replacing a command with the same name.
+// An alias that is the name of another command is ignored.
+//
+func registerCommand(commands map[string]*Command, command *Command) {
+	if prev, ok := commands[command.name]; ok && prev.name == command.name {
+		for n, c := range commands {
+			if c == prev {
+				delete(commands, n)
+			}
+		}
+	}
+
+	commands[command.name] = command
+
+	for _, alias := range command.aliases {
+		if c, ok := commands[alias]; ok && c.name == alias && c != command {
+			continue
+		}
+
+		commands[alias] = command
+	}
+}
+
+//
+// Find the deepest sub command matching the leading words.
+// Returns the command and the number of words that matched.
+//
+func (command *Command) findSubCommand(words []string) (*Command, int) {
+	n := 0
+	abbrev := command.cmdline != nil && command.cmdline.EnableAbbreviations
+
+	for _, word := range words {
+		subcommand, _ := lookupCommand(command.subCommands, word, abbrev)
+		if subcommand == nil {
+			break
+		}
+
+		command = subcommand
+		n++
+	}
+
+	return command, n
+}
+
+//
+// Returns the command registered as name or, if abbrev is set, the only command
+// with a name or alias starting with name. Hidden commands can't be abbreviated.
+// Returns an error listing the candidates if the abbreviation is ambiguous, and nil if there is no match.
+//
+func lookupCommand(commands map[string]*Command, name string, abbrev bool) (*Command, error) {
+	if command, ok := commands[name]; ok || !abbrev || len(name) == 0 {
+		return command, nil
 	}
 
-	subcommand.flags.Usage = func() {
-		fmt.Fprintf(os.Stderr, "%s %s -%s", command.alias, subcommand.alias, subcommand.help+"\n")
-		PrintDefaults(subcommand.flags)
+	var found *Command
+	var candidates []string
+
+	for _, command := range sortedCommands(commands) {
+		if command.hidden {
+			continue
+		}
+
+		matches := strings.HasPrefix(command.name, name)
+
+		for _, alias := range command.aliases {
+			matches = matches || (strings.HasPrefix(alias, name) && commands[alias] == command)
+		}
+
+		if matches {
+			found = command
+			candidates = append(candidates, command.name)
+		}
 	}
 
-	if len(command.alias) == 0 {
-		subcommand.alias = subcommand.name
+	if len(candidates) > 1 {
+		return nil, fmt.Errorf("ambiguous command: %s (%s)", name, strings.Join(candidates, ", "))
 	}
+
+	return found, nil
+}
+
+// Returns the sorted list of sub command names, including the aliases and excluding the hidden sub commands
+func (command *Command) subCommandNames() []string {
+	names := make([]string, 0, len(command.subCommands))
+
+	for n, c := range command.subCommands {
+		if !c.hidden {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Returns the full command name, including the names of the parent commands
+func (command *Command) path() string {
+	if command.parent != nil {
+		return command.parent.path() + " " + command.name
+	}
+
+	return command.name
+}
+
+// Returns the list of sub commands, sorted by name
+func (command *Command) subCommandList() []*Command {
+	return sortedCommands(command.subCommands)
+}
+
+// Returns the commands in the map (excluding the entries for the aliases), sorted by name
+func sortedCommands(commands map[string]*Command) []*Command {
+	list := make([]*Command, 0, len(commands))
+
+	for n, c := range commands {
+		if n == c.name {
+			list = append(list, c)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// Returns the first line of the help text
+func (command *Command) summary() string {
+	summary, _, _ := strings.Cut(strings.TrimSpace(command.help), "\n")
+	summary = strings.TrimSpace(summary)
+
+	if len(command.deprecated) > 0 {
+		summary += " (deprecated)"
+	}
+
+	return summary
+}
+
+// Returns the command name followed by its aliases, if any
+func (command *Command) nameAndAliases() string {
+	if len(command.aliases) == 0 {
+		return command.path()
+	}
+
+	return fmt.Sprintf("%s (aliases: %s)", command.path(), strings.Join(command.aliases, ", "))
 }
 
 func (command *Command) GetFlag(name string) string {
@@ -171,22 +538,50 @@ func (command *Command) GetBoolFlag(name string) bool {
 }
 
 func (command *Command) Usage() {
-	command.flags.Usage()
+	command.fullUsage(command.Stdout())
+}
 
-	for _, subcommand := range command.subCommands {
-		fmt.Println()
-		subcommand.flags.Usage()
+// print the usage of the command and its sub commands
+func (command *Command) fullUsage(w io.Writer) {
+	command.printUsage(w)
+
+	for _, subcommand := range command.subCommandList() {
+		if !subcommand.hidden {
+			fmt.Fprintln(w)
+			subcommand.printUsage(w)
+		}
 	}
 }
 
+func (command *Command) printUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s%s -%s", command.nameAndAliases(), command.argsUsage(), command.help+"\n")
+	FprintDefaults(w, command.flags)
+}
+
 //
 // This the the "context" for the command interpreter
 //
 type Cmd struct {
-	// the prompt string
+	// the prompt string.
+	// It can be a template (see PromptData) and it can span multiple lines.
+	// When the line editor is enabled, the colors are only displayed in the lines above
+	// the input line (the line editor doesn't support escape sequences in its prompt)
 	Prompt string
 
-	// the history file
+	// if set, this function is called to get the prompt string before reading each command
+	PromptFunc func() string
+
+	// the right-side prompt (also a template), displayed at the end of the line above the input line
+	// when the interpreter is running in a terminal
+	RightPrompt string
+
+	// the prompt string for the continuation lines of a command
+	// (when a line ends with a backslash or within quotes or brackets).
+	// A command that spans multiple lines is saved in the history as a single line,
+	// with the newlines replaced by spaces (including the newlines within quotes)
+	ContinuationPrompt string
+
+	// the history file (the aliases are saved to the same file with the ".aliases" extension)
 	HistoryFile string
 
 	// this function is called before starting the command loop
@@ -198,37 +593,143 @@ type Cmd struct {
 	// this function is called before executing the selected command
 	PreCmd func(string)
 
-	// this function is called after a command has been executed
+	// this function is called after a command has been executed,
+	// with the error returned by the command, if any.
 	// return true to terminate the interpreter, false to continue
-	PostCmd func(string, bool) bool
+	PostCmd func(string, bool, error) bool
+
+	// this function is called when a command fails.
+	// If not set, the error is displayed on the command error stream, unless it was already reported
+	OnError func(string, error)
 
 	// this function is called if the last typed command was an empty line
 	EmptyLine func()
 
-	// this function is called if the command line doesn't match any existing command
-	// by default it displays an error message
+	// this function is called if the command line doesn't match any existing command.
+	// If not set, an error message with the closest command names is displayed on the command error stream
 	Default func(string)
 
-	// this function is called to implement command completion.
+	// the maximum edit distance between an unknown command and the suggested command names
+	// (0 means the default distance of 2, a negative value disables the suggestions)
+	SuggestDistance int
+
+	// this function is called to implement command completion,
+	// when no completer was set for the command or flag being completed.
 	// it should return a list of words that match the input text
 	Complete func(string, string, int, int) []string
 
 	// if true, enable shell commands
 	EnableShell bool
 
+	// if true, a command or sub command can be abbreviated to a unique prefix of its name or aliases
+	EnableAbbreviations bool
+
+	// if true, add the "echo" command
+	EnableEcho bool
+
+	// if true, add the job control commands: "go", "jobs", "wait" and "kill"
+	EnableJobs bool
+
+	// if true, the output of the jobs is displayed while they run,
+	// in addition to being captured
+	TeeJobOutput bool
+
+	// the streams used by the interpreter and its commands.
+	// They default to os.Stdin, os.Stdout and os.Stderr. The line editor (with history
+	// and completion) is only used when reading from a terminal.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
 	// this is the list of available commands indexed by command name
 	Commands map[string]*Command
 
 	///////// private stuff /////////////
 
 	readline *liner.State
+	input    *bufio.Reader
+
+	completionNames []string // the command names and aliases, for completion
+
+	deprecationLock   sync.Mutex
+	deprecationWarned map[string]bool
 
-	commandNames []string
+	jobsLock  sync.Mutex
+	jobs      map[int]*Job
+	lastJobID int
 
-	waitGroup          *sync.WaitGroup
-	waitMax, waitCount int
+	varsLock sync.Mutex
+	vars     map[string]string
+
+	aliasesLock sync.Mutex
+	aliases     map[string]string
 
 	restartLoop bool
+
+	// cancelled when the command loop terminates
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	statusLock  sync.Mutex
+	lastError   error
+	exitStatus  int
+	lastElapsed time.Duration
+
+	promptTemplates map[string]*template.Template
+}
+
+// Exit status values
+const (
+	StatusOK             = 0
+	StatusError          = 1
+	StatusUsage          = 2
+	StatusUnknownCommand = 127
+)
+
+//
+// Returns the exit status of the last executed command:
+// StatusOK if it succeeded, StatusError if it failed, StatusUsage if it was called
+// with invalid flags or arguments and StatusUnknownCommand if it wasn't found
+//
+func (cmd *Cmd) ExitStatus() int {
+	cmd.statusLock.Lock()
+	defer cmd.statusLock.Unlock()
+
+	return cmd.exitStatus
+}
+
+//
+// Returns the error returned by the last executed command, or nil if it succeeded
+//
+func (cmd *Cmd) LastError() error {
+	cmd.statusLock.Lock()
+	defer cmd.statusLock.Unlock()
+
+	return cmd.lastError
+}
+
+func (cmd *Cmd) setStatus(err error) {
+	status := errorStatus(err)
+
+	cmd.statusLock.Lock()
+	cmd.lastError = err
+	cmd.exitStatus = status
+	cmd.statusLock.Unlock()
+}
+
+// Returns the exit status for the error returned by a command
+func errorStatus(err error) int {
+	var usage *UsageError
+
+	if errors.Is(err, ErrUnknownCommand) {
+		return StatusUnknownCommand
+	} else if errors.As(err, &usage) {
+		return StatusUsage
+	} else if err != nil {
+		return StatusError
+	}
+
+	return StatusOK
 }
 
 func (cmd *Cmd) readHistoryFile() {
@@ -244,7 +745,7 @@ func (cmd *Cmd) readHistoryFile() {
 			_, err := cmd.readline.ReadHistory(f)
 
 			if err != nil {
-				fmt.Println(err)
+				fmt.Fprintln(cmd.Stderr, err)
 			}
 
 			f.Close()
@@ -252,14 +753,13 @@ func (cmd *Cmd) readHistoryFile() {
 
 		return
 	} else {
-		fmt.Println(err)
+		fmt.Fprintln(cmd.Stderr, err)
 	}
 
 	filepath = path.Join(os.Getenv("HOME"), filepath) // then check home directory
 	if _, err := os.Stat(filepath); err == nil {
 
 		if f, err := os.Open(filepath); err == nil {
-			fmt.Println(err)
 			cmd.readline.ReadHistory(f)
 			f.Close()
 		}
@@ -276,7 +776,7 @@ func (cmd *Cmd) writeHistoryFile() {
 	}
 
 	if f, err := os.Create(cmd.HistoryFile); err != nil {
-		fmt.Print("Error writing history file: ", err)
+		fmt.Fprintln(cmd.Stderr, "Error writing history file:", err)
 	} else {
 		cmd.readline.WriteHistory(f)
 		f.Close()
@@ -292,307 +792,542 @@ func (cmd *Cmd) Init() {
 		cmd.PreLoop = func() {}
 	}
 	if cmd.PostLoop == nil {
-		cmd.PostLoop = func() { cmd.readline.Close() }
+		cmd.PostLoop = func() {
+			if cmd.readline != nil {
+				cmd.readline.Close()
+			}
+		}
 	}
 	if cmd.PreCmd == nil {
 		cmd.PreCmd = func(string) {}
 	}
 	if cmd.PostCmd == nil {
-		cmd.PostCmd = func(line string, stop bool) bool { return stop }
+		cmd.PostCmd = func(line string, stop bool, err error) bool { return stop }
 	}
 	if cmd.EmptyLine == nil {
 		cmd.EmptyLine = func() {}
 	}
-	if cmd.Default == nil {
-		cmd.Default = func(line string) { fmt.Printf("invalid command: %v\n", line) }
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
 	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+
+	cmd.ctx, cmd.cancel = context.WithCancel(context.Background())
 
-	cmd.readline = liner.NewLiner()
+	if cmd.isInteractive() {
+		cmd.readline = liner.NewLiner()
+	} else {
+		cmd.input = bufio.NewReader(cmd.Stdin)
+	}
 
 	cmd.Commands = make(map[string]*Command)
 
 	help := NewCommand("help",
 		SetHelp(`list available commands`),
+		SetBoolFlag("all", false, "list all commands and sub commands"),
 		SetCmd(cmd.Help))
 
 	cmd.Add(help)
-	//cmd.Add(Command{"echo", `echo input line`, cmd.Echo})
-	//cmd.Add(Command{"go", `go cmd: asynchronous execution of cmd, or 'go [--start|--wait]'`, cmd.Go})
+	cmd.addVarCommands()
+	cmd.addAliasCommands()
+
+	if cmd.EnableEcho {
+		cmd.Add(NewCommand("echo",
+			SetHelp(`echo input line`),
+			SetBoolFlag("n", false, "do not output the trailing newline"),
+			SetCmd(cmd.Echo)))
+	}
+
+	if cmd.EnableJobs {
+		cmd.addJobCommands()
+	}
 }
 
 //
-// Add a completer that matches on command names
+// Add a completer that matches on command and sub command names, flag names
+// and uses the command and flag completers for arguments and flag values
 //
 func (cmd *Cmd) AddCommandCompleter() {
-	cmd.commandNames = make([]string, 0, len(cmd.Commands))
+	cmd.updateCommandNames()
 
-	for n, _ := range cmd.Commands {
-		cmd.commandNames = append(cmd.commandNames, n)
+	if cmd.readline == nil {
+		return
 	}
 
-	// sorting for Help()
-	sort.Strings(cmd.commandNames)
+	cmd.readline.SetWordCompleter(cmd.completeWord)
+}
 
-	cmd.readline.SetCompleter(func(line string) (c []string) {
-		for _, n := range cmd.commandNames {
-			if strings.HasPrefix(n, strings.ToLower(line)) {
-				c = append(c, n)
-			}
+func (cmd *Cmd) updateCommandNames() {
+	cmd.completionNames = make([]string, 0, len(cmd.Commands))
+
+	for n, c := range cmd.Commands {
+		if !c.hidden {
+			cmd.completionNames = append(cmd.completionNames, n)
 		}
-		return
-	})
+	}
+
+	// sorting for completion
+	sort.Strings(cmd.completionNames)
+}
+
+// the interpreter streams
+func (cmd *Cmd) defaultStreams() streams {
+	return streams{stdin: cmd.Stdin, stdout: cmd.Stdout, stderr: cmd.Stderr}
+}
+
+//
+// Returns true if the interpreter is reading from and writing to a terminal,
+// in which case the line editor is used
+//
+func (cmd *Cmd) isInteractive() bool {
+	return cmd.Stdin == os.Stdin && cmd.Stdout == os.Stdout &&
+		isTerminal(os.Stdin) && liner.TerminalSupported()
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+//
+// read the next input line, using the line editor if available
+//
+func (cmd *Cmd) readLine(prompt string) (string, error) {
+	if cmd.readline != nil {
+		return cmd.readline.Prompt(prompt)
+	}
+
+	fmt.Fprint(cmd.Stdout, prompt)
+
+	line, err := cmd.input.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+//
+// read a command, that continues on the next lines if the line ends with a backslash
+// or within quotes or brackets
+//
+func (cmd *Cmd) readCommand(prompt string) (string, error) {
+	line, err := cmd.readLine(prompt)
+
+	for err == nil {
+		text, sep, more := continuation(line)
+		if !more {
+			return line, nil
+		}
+
+		var next string
+
+		if next, err = cmd.readLine(cmd.ContinuationPrompt); err == nil {
+			line = text + sep + next
+		} else if err == io.EOF {
+			err = errors.New("syntax error: unexpected end of input")
+		}
+	}
+
+	return "", err
 }
 
 //
-// execute shell command
+// execute shell command (the arguments are already tokenized)
 //
-func shellExec(command string) {
-	args := args.GetArgs(command)
+func (cmd *Cmd) shellExec(ctx context.Context, s streams, args []string) (err error) {
 	if len(args) < 1 {
-		fmt.Println("No command to exec")
+		err = errors.New("no command to exec")
 	} else {
-		var cmd *exec.Cmd
+		var shell *exec.Cmd
 
 		if runtime.GOOS == "windows" {
 			cmdArgs := []string{"cmd", "/C"}
 			cmdArgs = append(cmdArgs, args...)
-			cmd = exec.Command(cmdArgs[0], cmdArgs[1:]...)
+			shell = exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
 		} else {
-			cmd = exec.Command(args[0])
-			cmd.Args = args
+			shell = exec.CommandContext(ctx, args[0])
+			shell.Args = args
 		}
 
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		shell.Stdin = s.stdin
+		shell.Stdout = s.stdout
+		shell.Stderr = s.stderr
 
-		if err := cmd.Run(); err != nil {
-			fmt.Println(err)
-		}
+		err = shell.Run()
 	}
+
+	return
 }
 
 // Add a command to the command interpreter.
+// The command is registered under its name and its aliases.
 // Overrides a command with the same name, if there was one
 //
 func (cmd *Cmd) Add(command *Command) {
-	if len(command.alias) > 0 && command.alias != command.name {
-		cmd.Commands[command.alias] = command
-	} else {
-		cmd.Commands[command.name] = command
-	}
+	registerCommand(cmd.Commands, command)
+
+	command.setCmdline(cmd)
+	cmd.updateCommandNames()
 }
 
 //
 // Default help command.
-// It lists all available commands or it displays the help for the specified command
+// It lists all available commands, grouped by category, or it displays the help for the specified command.
+// With -all, the sub commands and the hidden commands are also listed.
 //
 func (cmd *Cmd) Help(command *Command, line string) (stop bool) {
-	fmt.Println("")
-
-	if len(line) == 0 {
-		fmt.Println("Available commands (use 'help <topic>'):")
-		fmt.Println("================================================================")
+	w := command.Stdout()
+	args := command.Args()
 
-		tp := pretty.NewTabPrinter(8)
+	fmt.Fprintln(w, "")
 
-		for _, c := range cmd.commandNames {
-			tp.Print(c)
-		}
+	if len(args) == 0 {
+		fmt.Fprintln(w, "Available commands (use 'help <topic>'):")
+		fmt.Fprintln(w, "================================================================")
 
-		tp.Println()
+		cmd.printCommandList(w, command.GetBoolFlag("all"))
 	} else {
 
-		args := strings.Split(line, " ")
-
-		if len(args) > 1 {
+		unknown := args[:1]
 
-			if c, ok := cmd.Commands[args[0]]; ok {
+		c, err := lookupCommand(cmd.Commands, args[0], cmd.EnableAbbreviations)
+		ok := c != nil
+		if ok {
+			var n int
 
-				cm, ok := c.subCommands[args[1]]
-				if ok {
-					if len(cm.help) > 0 {
-						cm.Usage()
-					} else {
-						fmt.Println("No help for ", line)
-					}
-				} else {
-					fmt.Println("unknown command")
-				}
+			if c, n = c.findSubCommand(args[1:]); n < len(args)-1 {
+				unknown = args[:n+2]
+				ok = false
 			}
+		}
 
-		} else {
-
-			c, ok := cmd.Commands[line]
-			if ok {
-				if len(c.help) > 0 {
-					c.Usage()
-				} else {
-					fmt.Println("No help for ", line)
-				}
+		if ok {
+			if len(c.help) > 0 {
+				c.fullUsage(w)
 			} else {
-				fmt.Println("unknown command")
+				fmt.Fprintln(w, "No help for ", line)
 			}
+		} else if err != nil {
+			fmt.Fprintln(w, err)
+		} else {
+			fmt.Fprintln(w, "unknown command")
+			cmd.printSuggestions(w, unknown)
 		}
 	}
 
-	fmt.Println("")
+	fmt.Fprintln(w, "")
 	return
 }
 
-func (cmd *Cmd) Echo(line string) (stop bool) {
-	fmt.Println(line)
-	return
-}
+//
+// print the commands with their help summary, grouped by category.
+// The commands without a category are listed last.
+//
+func (cmd *Cmd) printCommandList(w io.Writer, all bool) {
+	groups := make(map[string][]*Command)
+	categories := []string{}
 
-func (cmd *Cmd) Go(line string) (stop bool) {
-	if strings.HasPrefix(line, "-") {
-		// should be --start or --wait
+	for _, c := range sortedCommands(cmd.Commands) {
+		if c.hidden && !all {
+			continue
+		}
 
-		args := args.ParseArgs(line)
+		if _, ok := groups[c.category]; !ok && len(c.category) > 0 {
+			categories = append(categories, c.category)
+		}
 
-		if _, ok := args.Options["start"]; ok {
-			cmd.waitGroup = new(sync.WaitGroup)
-			cmd.waitCount = 0
-			cmd.waitMax = 0
+		groups[c.category] = append(groups[c.category], c)
+	}
 
-			if len(args.Arguments) > 0 {
-				cmd.waitMax, _ = strconv.Atoi(args.Arguments[0])
-			}
+	sort.Strings(categories)
 
-			return
-		}
+	if _, ok := groups[""]; ok {
+		categories = append(categories, "")
+	}
 
-		if _, ok := args.Options["wait"]; ok {
-			if cmd.waitGroup == nil {
-				fmt.Println("nothing to wait on")
-			} else {
-				cmd.waitGroup.Wait()
-				cmd.waitGroup = nil
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	for _, category := range categories {
+		if len(categories) > 1 {
+			heading := category
+			if len(heading) == 0 {
+				heading = "Other"
 			}
 
-			return
+			fmt.Fprintf(tw, "\n%s:\n", heading)
+		}
+
+		for _, c := range groups[category] {
+			printSummary(tw, c, all)
 		}
 	}
 
-	if strings.HasPrefix(line, "go ") {
-		fmt.Println("Don't go go me!")
-	} else {
-		if cmd.waitGroup == nil {
-			go cmd.OneCmd(line)
-		} else {
-			if cmd.waitMax > 0 {
-				if cmd.waitCount >= cmd.waitMax {
-					cmd.waitGroup.Wait()
-					cmd.waitCount = 0
-				}
-			}
+	tw.Flush()
+}
 
-			cmd.waitCount++
-			cmd.waitGroup.Add(1)
+// print the command name and the help summary (and the sub commands, including the hidden ones, if all is set)
+func printSummary(w io.Writer, command *Command, all bool) {
+	fmt.Fprintf(w, "  %s\t%s\n", command.path(), command.summary())
 
-			go func() {
-				defer cmd.waitGroup.Done()
-				cmd.OneCmd(line)
-			}()
+	if all {
+		for _, subcommand := range command.subCommandList() {
+			printSummary(w, subcommand, all)
 		}
 	}
+}
+
+//
+// Echo command: it displays the input line (without a final newline if -n is specified)
+//
+func (cmd *Cmd) Echo(command *Command, line string) (stop bool) {
+	text := strings.Join(command.Args(), " ")
+
+	if command.GetBoolFlag("n") {
+		fmt.Fprint(command.Stdout(), text)
+	} else {
+		fmt.Fprintln(command.Stdout(), text)
+	}
 
 	return
 }
 
-// Simple processing of quotes strings in command line args
-// This just treats a quotes string as a indivisible piece
-func processQuotes(line string) (args []string) {
+//
+// This method executes one command.
+// The error returned by the command, if any, is passed to OnError and is available from LastError.
+// The command context is cancelled if the user presses Ctrl-C while the command is running.
+//
+func (cmd *Cmd) OneCmd(line string) (stop bool) {
+	stop, _ = cmd.executeForeground(line)
+	return
+}
 
-	lastQuote := rune(0)
+//
+// execute one command, cancelling its context on interrupt
+//
+func (cmd *Cmd) executeForeground(line string) (stop bool, err error) {
+	ctx, cancel := signal.NotifyContext(cmd.ctx, os.Interrupt)
+	defer cancel()
 
-	f := func(ch rune) bool {
-		switch {
-		case ch == lastQuote:
-			lastQuote = rune(0)
-			return false
-		case lastQuote != rune(0):
-			return false
-		case unicode.In(ch, unicode.Quotation_Mark):
-			lastQuote = ch
-			return false
-		default:
-			return unicode.IsSpace(ch)
-		}
-	}
+	return cmd.execute(ctx, line)
+}
 
-	args = strings.FieldsFunc(line, f)
+//
+// execute one command, updating the exit status
+//
+func (cmd *Cmd) execute(ctx context.Context, line string) (stop bool, err error) {
+	stop, executed, err := cmd.dispatch(ctx, cmd.defaultStreams(), line, true)
 
-	for i, arg := range args {
-		args[i] = strings.Replace(arg, "\"", "", -1)
+	cmd.setStatus(err)
+
+	if err != nil {
+		cmd.reportError(cmd.defaultStreams(), executed, err)
 	}
 
-	return args
+	return
 }
 
 //
-// This method executes one command
+// find the command matching the line and call it, returning ErrUnknownCommand
+// if the line doesn't match any registered command
 //
-func (cmd *Cmd) OneCmd(line string) (stop bool) {
+func (cmd *Cmd) runCommand(ctx context.Context, s streams, line string) (stop bool, err error) {
 
 	if cmd.EnableShell && strings.HasPrefix(line, "!") {
-		shellExec(line[1:])
-		return
+		tokens, err := tokenize(line[1:], cmd.varLookup(ctx))
+		if err != nil {
+			return false, err
+		}
+
+		return false, cmd.shellExec(ctx, s, tokenValues(tokens))
 	}
 
-	parts := strings.SplitN(line, " ", 2)
-	cname := parts[0]
+	lookup := cmd.varLookup(ctx)
 
-	command, ok := cmd.Commands[cname]
+	tokens, err := tokenize(line, lookup)
+	if err != nil {
+		return false, err
+	}
 
-	if ok {
-		var params string
+	args := tokenValues(tokens)
 
-		if len(parts) > 1 {
-			splitLine := strings.SplitN(parts[1], " ", 2)
-			subcmd := splitLine[0]
+	cname := ""
+	if len(args) > 0 {
+		cname = args[0]
+	}
 
-			subcommand, ok := command.subCommands[subcmd]
-			if ok {
-				if len(splitLine) > 2 {
+	command, err := lookupCommand(cmd.Commands, cname, cmd.EnableAbbreviations)
+	if err != nil {
+		return false, err
+	}
 
-					params = strings.TrimSpace(splitLine[1])
-				}
+	if command != nil {
+		depth := 1
 
-				//args := strings.Split(line, " ")
+		// walk down the sub commands
+		for ; depth < len(args); depth++ {
+			subcommand, err := lookupCommand(command.subCommands, args[depth], cmd.EnableAbbreviations)
+			if err != nil {
+				return false, err
+			}
 
-				args := processQuotes(line)
+			if subcommand == nil {
+				break
+			}
 
-				subcommand.flags.Parse(args[2:])
+			command = subcommand
+		}
 
-				subcommand.cmdline = cmd
-				stop = subcommand.call(subcommand, params)
+		// the parameters are passed to the command as typed, with the variables expanded
+		raw, params := "", ""
+		if depth < len(tokens) {
+			raw = strings.TrimSpace(line[tokens[depth].start:])
 
-				subcommand.flags.VisitAll(func(flag *flag.Flag) {
-					flag.Value.Set(flag.DefValue)
-				})
-				return
+			if params, err = cmd.expandVars(raw, lookup); err != nil {
+				return false, err
 			}
+		}
 
-			params = strings.TrimSpace(parts[1])
+		return cmd.invoke(ctx, s, command, args[depth:], params, raw)
+
+	} else {
+		if line, err = cmd.expandVars(line, lookup); err != nil {
+			return false, err
 		}
 
-		args := processQuotes(line)
-		command.flags.Parse(args[1:])
-		command.cmdline = cmd
-		stop = command.call(command, params)
+		cmd.unknownCommand(s, line)
+		err = ErrUnknownCommand
+	}
 
-		command.flags.VisitAll(func(flag *flag.Flag) {
-			flag.Value.Set(flag.DefValue)
-		})
+	return
+}
 
-	} else {
+//
+// call OnError or, if it's not set, display the error on the error stream
+// (the usage errors and unknown commands are not displayed, since they were already reported)
+//
+func (cmd *Cmd) reportError(s streams, line string, err error) {
+	if cmd.OnError != nil {
+		cmd.OnError(line, err)
+		return
+	}
+
+	var usage *UsageError
+
+	if errors.As(err, &usage) || errors.Is(err, ErrUnknownCommand) {
+		return
+	}
+
+	fmt.Fprintln(s.stderr, "error:", err)
+}
+
+//
+// call Default or, if it's not set, display an error message
+// with the closest command names on the error stream
+//
+func (cmd *Cmd) unknownCommand(s streams, line string) {
+	if cmd.Default != nil {
 		cmd.Default(line)
+		return
+	}
+
+	fmt.Fprintf(s.stderr, "invalid command: %v\n", line)
+
+	if name, _ := nextWord(line); len(name) > 0 {
+		cmd.printSuggestions(s.stderr, []string{name})
+	}
+}
+
+// split the first word from the rest of the line
+func nextWord(line string) (word, rest string) {
+	line = strings.TrimLeftFunc(line, unicode.IsSpace)
+
+	if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+		return line[:i], strings.TrimLeftFunc(line[i:], unicode.IsSpace)
+	}
+
+	return line, ""
+}
+
+//
+// parse the command flags and call the command
+//
+func (cmd *Cmd) invoke(ctx context.Context, s streams, command *Command, args []string, params, raw string) (stop bool, err error) {
+	command = command.newInvocation(cmd, ctx, s)
+	command.rawParams = raw
+	command.flags.SetOutput(command.Stderr())
+
+	if err = command.flags.Parse(args); err == flag.ErrHelp {
+		// the usage was requested and has been displayed
+		err = nil
+	} else if err != nil {
+		err = &UsageError{err}
+	} else if err = command.parseArgs(command.flags.Args()); err != nil {
+		fmt.Fprintln(command.Stderr(), err)
+		command.flags.Usage()
+		err = &UsageError{err}
+	} else {
+		cmd.warnDeprecated(command)
+		stop, err = command.call(command, params)
 	}
 
 	return
 }
 
+//
+// display a warning, once, if the command or any of the flags used is deprecated
+//
+func (cmd *Cmd) warnDeprecated(command *Command) {
+	if len(command.deprecated) > 0 {
+		cmd.warnOnce(command.Stderr(), command.path(), command.deprecated)
+	}
+
+	command.flags.Visit(func(f *flag.Flag) {
+		if message, ok := command.deprecatedFlags[f.Name]; ok {
+			cmd.warnOnce(command.Stderr(), command.path()+" -"+f.Name, message)
+		}
+	})
+}
+
+func (cmd *Cmd) warnOnce(w io.Writer, name, message string) {
+	cmd.deprecationLock.Lock()
+	defer cmd.deprecationLock.Unlock()
+
+	if cmd.deprecationWarned[name] {
+		return
+	}
+
+	if cmd.deprecationWarned == nil {
+		cmd.deprecationWarned = make(map[string]bool)
+	}
+
+	cmd.deprecationWarned[name] = true
+	fmt.Fprintf(w, "warning: %q is deprecated, %s\n", name, message)
+}
+
+//
+// execute one command, calling the PreCmd and PostCmd hooks around it
+//
+func (cmd *Cmd) runLine(line string) (stop bool, err error) {
+	cmd.PreCmd(line)
+
+	start := time.Now()
+	stop, err = cmd.executeForeground(line)
+
+	cmd.statusLock.Lock()
+	cmd.lastElapsed = time.Since(start).Round(time.Millisecond)
+	cmd.statusLock.Unlock()
+
+	stop = cmd.PostCmd(line, stop, err)
+
+	return
+}
+
 //
 // This is the command interpreter entry point.
 // It displays a prompt, waits for a command and executes it until the selected command returns true
@@ -602,22 +1337,38 @@ func (cmd *Cmd) CmdLoop() {
 		cmd.Prompt = "> "
 	}
 
+	if len(cmd.ContinuationPrompt) == 0 {
+		cmd.ContinuationPrompt = "... "
+	}
+
+	if cmd.ctx.Err() != nil {
+		// restarting a terminated loop
+		cmd.ctx, cmd.cancel = context.WithCancel(context.Background())
+	}
+
 	cmd.AddCommandCompleter()
 
 	cmd.PreLoop()
 
 	cmd.readHistoryFile()
+	cmd.readAliasFile()
 
 	// loop until ReadLine returns nil (signalling EOF)
 	for {
-		result, err := cmd.readline.Prompt(cmd.Prompt)
+		result, err := cmd.readCommand(cmd.nextPrompt())
 		if err != nil {
 
 			if err == io.EOF {
 				break
 			}
 
-			fmt.Println(err)
+			fmt.Fprintln(cmd.Stderr, err)
+
+			if cmd.readline == nil {
+				// no line editor to recover the input stream
+				break
+			}
+
 			continue
 		}
 
@@ -627,19 +1378,25 @@ func (cmd *Cmd) CmdLoop() {
 			continue
 		}
 
-		cmd.readline.AppendHistory(result) // allow user to recall this line
-
-		cmd.PreCmd(line)
+		if cmd.readline != nil {
+			// allow user to recall this line.
+			// The history entries can't span multiple lines, so a multi-line command is recalled as a single line
+			// (a newline within quotes becomes a space in the recalled command)
+			cmd.readline.AppendHistory(strings.Replace(result, "\n", " ", -1))
+		}
 
-		stop := cmd.OneCmd(line)
-		stop = cmd.PostCmd(line, stop)
+		stop, _ := cmd.runLine(line)
 
 		if stop {
 			break
 		}
 	}
 
+	// cancel the commands still running in the background
+	cmd.cancel()
+
 	cmd.writeHistoryFile()
+	cmd.writeAliasFile()
 
 	cmd.PostLoop()
 }
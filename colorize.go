@@ -3,6 +3,11 @@
 
 package cmd
 
-func ColorizeString(text string) {
+import (
+	"fmt"
+)
 
+// Display the text, with the ANSI color sequences interpreted by the terminal
+func ColorizeString(text string) {
+	fmt.Print(text)
 }
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"flag"
+	"strings"
+)
+
+//
+// A completion function: it's called with the word to complete, the full line
+// and the start and end positions of the word in the line,
+// and should return a list of words that match the input text
+//
+type CompleterFunc func(text, line string, start, end int) []string
+
+// Set the completer for the command arguments
+func SetCompleter(completer CompleterFunc) Option {
+	return func(command *Command) {
+		command.completer = completer
+	}
+}
+
+// Set the completer for the values of the specified flag
+func SetFlagCompleter(flag string, completer CompleterFunc) Option {
+	return func(command *Command) {
+		if command.flagCompleters == nil {
+			command.flagCompleters = make(map[string]CompleterFunc)
+		}
+
+		command.flagCompleters[flag] = completer
+	}
+}
+
+//
+// Complete the word at position pos in line.
+// Returns the line before the word, the list of completions for the word and the rest of the line.
+// The completions are quoted if they contain spaces or special characters.
+//
+func (cmd *Cmd) completeWord(line string, pos int) (head string, c []string, tail string) {
+	head, tail = line[:pos], line[pos:]
+
+	defer func() {
+		for i, w := range c {
+			if len(w) > 0 {
+				c[i] = quoteWord(w)
+			}
+		}
+	}()
+
+	// the line is tokenized as it would be executed, ignoring unterminated quotes
+	tokens, _ := tokenize(head, nil)
+
+	start := len(head)
+	text := ""
+
+	if n := len(tokens); n > 0 && !tokens[n-1].op && tokens[n-1].end == len(head) {
+		// completing the last word
+		start, text = tokens[n-1].start, tokens[n-1].value
+		tokens = tokens[:n-1]
+	}
+
+	head = head[:start]
+
+	// only the last command in the line is completed
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].op {
+			if op := tokens[i].value; (op == ">" || op == ">>") && i == len(tokens)-1 {
+				// output redirection
+				c = cmd.completeDefault(nil, text, line, start, pos)
+				return
+			}
+
+			tokens = tokens[i+1:]
+			break
+		}
+	}
+
+	words := tokenValues(tokens)
+
+	if len(words) == 0 {
+		prefix := strings.ToLower(text)
+
+		for _, n := range cmd.completionNames {
+			if strings.HasPrefix(n, prefix) {
+				c = append(c, n)
+			}
+		}
+
+		return
+	}
+
+	command, ok := cmd.Commands[words[0]]
+	if !ok {
+		c = cmd.completeDefault(nil, text, line, start, pos)
+		return
+	}
+
+	command, n := command.findSubCommand(words[1:])
+	args := words[1+n:]
+
+	if strings.HasPrefix(text, "-") {
+		if eq := strings.Index(text, "="); eq >= 0 {
+			// -flag=value
+			name := strings.TrimLeft(text[:eq], "-")
+
+			for _, v := range cmd.completeFlag(command, name, text[eq+1:], line, start+eq+1, pos) {
+				c = append(c, text[:eq+1]+v)
+			}
+		} else {
+			c = command.completeFlagNames(text)
+		}
+
+		return
+	}
+
+	if len(args) > 0 {
+		if name, ok := flagWithValue(command.flags, args[len(args)-1]); ok {
+			c = cmd.completeFlag(command, name, text, line, start, pos)
+			return
+		}
+	}
+
+	if len(args) == 0 {
+		for _, n := range command.subCommandNames() {
+			if strings.HasPrefix(n, text) {
+				c = append(c, n)
+			}
+		}
+	}
+
+	c = append(c, cmd.completeDefault(command.completer, text, line, start, pos)...)
+	return
+}
+
+// complete the value for the named flag
+func (cmd *Cmd) completeFlag(command *Command, name, text, line string, start, end int) []string {
+	return cmd.completeDefault(command.flagCompleters[name], text, line, start, end)
+}
+
+// call the completer, or Cmd.Complete if there is none
+func (cmd *Cmd) completeDefault(completer CompleterFunc, text, line string, start, end int) []string {
+	if completer == nil {
+		completer = cmd.Complete
+	}
+
+	if completer == nil {
+		return nil
+	}
+
+	return completer(text, line, start, end)
+}
+
+// complete the flag names matching text, keeping the leading dashes
+func (command *Command) completeFlagNames(text string) (c []string) {
+	prefix := strings.TrimLeft(text, "-")
+	dashes := text[:len(text)-len(prefix)]
+
+	command.flags.VisitAll(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, prefix) {
+			c = append(c, dashes+f.Name)
+		}
+	})
+
+	return
+}
+
+//
+// Returns the flag name if word is a flag that expects a value in the next word
+// (i.e. it's not a boolean flag and is not in the form -flag=value)
+//
+func flagWithValue(flags *flag.FlagSet, word string) (string, bool) {
+	if !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return "", false
+	}
+
+	f := flags.Lookup(strings.TrimLeft(word, "-"))
+	if f == nil {
+		return "", false
+	}
+
+	if b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	}); ok && b.IsBoolFlag() {
+		return "", false
+	}
+
+	return f.Name, true
+}
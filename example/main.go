@@ -35,7 +35,14 @@ func Exit(command *cmd.Command,line string) (stop bool) {
 }
 
 func main() {
-	commander := &cmd.Cmd{HistoryFile: ".rlhistory", Complete: CompletionFunction, EnableShell: true}
+	commander := &cmd.Cmd{
+		HistoryFile: ".rlhistory",
+		Complete:    CompletionFunction,
+		EnableShell: true,
+		EnableJobs:  true,
+		Prompt:      "{{if .Status}}{{color \"red\" (printf \"exit status %d\" .Status)}}\n{{end}}> ",
+		RightPrompt: `{{if .Jobs}}{{.Jobs}} jobs {{end}}{{color "gray" .Elapsed}}`,
+	}
 	commander.Init()
 
 	text := fmt.Sprintf("%c[%dm%s\033[0m", 0x1B, 31,"red bold")
@@ -45,10 +52,10 @@ func main() {
 	list := cmd.NewCommand(
 		"ls",
 		cmd.SetHelp(`list stuff`),
-		cmd.SetFlag("number","","only list this number of things"),
+		cmd.SetIntFlag("number",0,"only list this number of things"),
 		cmd.SetCmd(func(command *cmd.Command,line string) (stop bool) {
 
-			if num := command.GetFlag("number"); len(num) > 0 {
+			if num, _ := command.GetIntFlag("number"); num > 0 {
 				fmt.Println("list only",num, "stuff")
 			} else {
 				fmt.Println("listing stuff")
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func SetIntFlag(name string, value int, help string) Option {
+	return func(command *Command) {
+		command.addFlag(func(f *flag.FlagSet) { f.Int(name, value, help) })
+	}
+}
+
+func SetDurationFlag(name string, value time.Duration, help string) Option {
+	return func(command *Command) {
+		command.addFlag(func(f *flag.FlagSet) { f.Duration(name, value, help) })
+	}
+}
+
+func SetFloatFlag(name string, value float64, help string) Option {
+	return func(command *Command) {
+		command.addFlag(func(f *flag.FlagSet) { f.Float64(name, value, help) })
+	}
+}
+
+// Set a flag that can be repeated: each occurrence adds a value to the list.
+// The default values are replaced by the first occurrence.
+func SetStringSliceFlag(name string, value []string, help string) Option {
+	return func(command *Command) {
+		command.addFlag(func(f *flag.FlagSet) { f.Var(newStringSliceValue(value), name, help) })
+	}
+}
+
+// Set a flag that only accepts one of the allowed values
+func SetEnumFlag(name string, value string, allowed []string, help string) Option {
+	return func(command *Command) {
+		command.addFlag(func(f *flag.FlagSet) { f.Var(&enumValue{value: value, allowed: allowed}, name, help) })
+	}
+}
+
+//
+// A repeatable string flag
+//
+type stringSliceValue struct {
+	values  []string
+	changed bool
+}
+
+func newStringSliceValue(defaults []string) *stringSliceValue {
+	return &stringSliceValue{values: append([]string(nil), defaults...)}
+}
+
+func (v *stringSliceValue) Set(s string) error {
+	if !v.changed {
+		v.values = nil
+		v.changed = true
+	}
+
+	v.values = append(v.values, s)
+	return nil
+}
+
+func (v *stringSliceValue) String() string {
+	if v == nil {
+		return ""
+	}
+
+	return strings.Join(v.values, ",")
+}
+
+func (v *stringSliceValue) Get() interface{} {
+	return append([]string(nil), v.values...)
+}
+
+//
+// A string flag restricted to a set of values
+//
+type enumValue struct {
+	value   string
+	allowed []string
+}
+
+func (v *enumValue) Set(s string) error {
+	for _, a := range v.allowed {
+		if s == a {
+			v.value = s
+			return nil
+		}
+	}
+
+	return fmt.Errorf("must be one of %s", strings.Join(v.allowed, ", "))
+}
+
+func (v *enumValue) String() string {
+	if v == nil {
+		return ""
+	}
+
+	return v.value
+}
+
+func (v *enumValue) Get() interface{} {
+	return v.value
+}
+
+// Returns the type of the flag value, as displayed in the usage, or an empty string for boolean flags
+func flagType(f *flag.Flag) string {
+	if v, ok := f.Value.(*enumValue); ok {
+		return "{" + strings.Join(v.allowed, "|") + "}"
+	}
+
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return "value"
+	}
+
+	switch getter.Get().(type) {
+	case bool:
+		return ""
+	case int, int64, uint, uint64:
+		return "int"
+	case float64:
+		return "float"
+	case time.Duration:
+		return "duration"
+	case string:
+		return "string"
+	case []string:
+		return "string..."
+	}
+
+	return "value"
+}
+
+// Returns true if the flag default is the zero value for its type
+func isZeroDefault(f *flag.Flag) bool {
+	switch f.DefValue {
+	case "", "0", "0s", "false":
+		return true
+	}
+
+	return false
+}
+
+func (command *Command) lookupFlag(name string) (*flag.Flag, error) {
+	f := command.flags.Lookup(name)
+	if f == nil {
+		return nil, fmt.Errorf("flag provided but not defined: -%s", name)
+	}
+
+	return f, nil
+}
+
+// Returns the flag value, if it's of the expected type, or its string representation
+func (command *Command) getFlagValue(name string) (interface{}, string, error) {
+	f, err := command.lookupFlag(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if getter, ok := f.Value.(flag.Getter); ok {
+		return getter.Get(), f.Value.String(), nil
+	}
+
+	return nil, f.Value.String(), nil
+}
+
+func (command *Command) GetIntFlag(name string) (int, error) {
+	v, s, err := command.getFlagValue(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if i, ok := v.(int); ok {
+		return i, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for flag -%s: not an int", s, name)
+	}
+
+	return i, nil
+}
+
+func (command *Command) GetDurationFlag(name string) (time.Duration, error) {
+	v, s, err := command.getFlagValue(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if d, ok := v.(time.Duration); ok {
+		return d, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for flag -%s: not a duration", s, name)
+	}
+
+	return d, nil
+}
+
+func (command *Command) GetFloatFlag(name string) (float64, error) {
+	v, s, err := command.getFlagValue(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if f, ok := v.(float64); ok {
+		return f, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q for flag -%s: not a number", s, name)
+	}
+
+	return f, nil
+}
+
+// Returns the values of a repeatable flag (or the value of a string flag, as a single element list)
+func (command *Command) GetStringSliceFlag(name string) ([]string, error) {
+	v, s, err := command.getFlagValue(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if l, ok := v.([]string); ok {
+		return l, nil
+	}
+
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	return []string{s}, nil
+}
+
+func (command *Command) GetEnumFlag(name string) (string, error) {
+	f, err := command.lookupFlag(name)
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := f.Value.(*enumValue)
+	if !ok {
+		return "", fmt.Errorf("flag -%s is not an enum", name)
+	}
+
+	return v.value, nil
+}
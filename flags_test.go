@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTypedFlags(t *testing.T) {
+	var out bytes.Buffer
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	commander.Init()
+
+	var got string
+
+	commander.Add(NewCommand("typed",
+		SetIntFlag("n", 3, "count"),
+		SetDurationFlag("t", time.Second, "timeout"),
+		SetFloatFlag("f", 0.5, "ratio"),
+		SetStringSliceFlag("tag", []string{"a", "b"}, "tags"),
+		SetEnumFlag("format", "json", []string{"json", "yaml"}, "format"),
+		SetCmdE(func(command *Command, line string) error {
+			n, err := command.GetIntFlag("n")
+			if err != nil {
+				return err
+			}
+
+			d, _ := command.GetDurationFlag("t")
+			f, _ := command.GetFloatFlag("f")
+			tags, _ := command.GetStringSliceFlag("tag")
+			format, _ := command.GetEnumFlag("format")
+
+			got = fmt.Sprintf("%v %v %v %v %v", n, d, f, tags, format)
+			return nil
+		})))
+
+	tests := []struct {
+		line   string
+		want   string
+		status int
+	}{
+		{"typed", "3 1s 0.5 [a b] json", StatusOK},
+		{"typed -n 5 -t 2m -f 1.5", "5 2m0s 1.5 [a b] json", StatusOK},
+		{"typed -tag x -tag y -format yaml", "3 1s 0.5 [x y] yaml", StatusOK},
+		{"typed -n x", "", StatusUsage},
+		{"typed -t 5", "", StatusUsage},
+		{"typed -f one", "", StatusUsage},
+		{"typed -format xml", "", StatusUsage},
+	}
+
+	for _, tt := range tests {
+		got = ""
+		commander.OneCmd(tt.line)
+
+		if got != tt.want || commander.ExitStatus() != tt.status {
+			t.Errorf("%q: %q, status %d, want %q, %d", tt.line, got, commander.ExitStatus(), tt.want, tt.status)
+		}
+	}
+}
+
+func TestTypedFlagErrors(t *testing.T) {
+	var errs []string
+
+	command := NewCommand("test",
+		SetFlag("s", "abc", "string"),
+		SetFlag("i", "42", "int as string"),
+		SetBoolFlag("b", false, "bool"),
+		SetCmdE(func(command *Command, line string) error {
+			_, err := command.GetIntFlag("s")
+			errs = append(errs, fmt.Sprint(err))
+
+			i, err := command.GetIntFlag("i")
+			errs = append(errs, fmt.Sprint(i, err))
+
+			_, err = command.GetDurationFlag("s")
+			errs = append(errs, fmt.Sprint(err))
+
+			_, err = command.GetFloatFlag("missing")
+			errs = append(errs, fmt.Sprint(err))
+
+			_, err = command.GetEnumFlag("s")
+			errs = append(errs, fmt.Sprint(err))
+
+			l, err := command.GetStringSliceFlag("s")
+			errs = append(errs, fmt.Sprint(l, err))
+			return nil
+		}))
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	commander.Init()
+	commander.Add(command)
+	commander.OneCmd("test")
+
+	want := []string{
+		`invalid value "abc" for flag -s: not an int`,
+		"42 <nil>",
+		`invalid value "abc" for flag -s: not a duration`,
+		"flag provided but not defined: -missing",
+		"flag -s is not an enum",
+		"[abc] <nil>",
+	}
+
+	if strings.Join(errs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(errs, "\n"), strings.Join(want, "\n"))
+	}
+}
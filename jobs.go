@@ -0,0 +1,396 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+//
+// This describes a command running in the background, started with Go or StartJob
+//
+type Job struct {
+	// the job identifier
+	ID int
+	// the command line
+	Line string
+	// the time the job was started
+	Start time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	output *syncBuffer
+
+	lock   sync.Mutex
+	end    time.Time
+	err    error
+	killed bool
+}
+
+// Returns true if the job has terminated
+func (job *Job) Done() bool {
+	select {
+	case <-job.done:
+		return true
+	default:
+		return false
+	}
+}
+
+//
+// This is the result of a terminated job
+//
+type JobResult struct {
+	// the job identifier
+	ID int
+	// the command line
+	Line string
+	// the output of the command (including the error stream)
+	Output string
+	// the error returned by the command, if any
+	Err error
+	// how long the job ran
+	Elapsed time.Duration
+}
+
+// Wait for the job to terminate and return its result
+func (job *Job) Wait() JobResult {
+	<-job.done
+
+	return JobResult{
+		ID:      job.ID,
+		Line:    job.Line,
+		Output:  job.Output(),
+		Err:     job.Err(),
+		Elapsed: job.Elapsed(),
+	}
+}
+
+// Returns the output of the command so far (including the error stream)
+func (job *Job) Output() string {
+	return job.output.String()
+}
+
+// Returns the error returned by the command, or nil if it succeeded or it's still running
+func (job *Job) Err() error {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	return job.err
+}
+
+// Cancel the job context
+func (job *Job) Kill() {
+	job.lock.Lock()
+	job.killed = !job.Done()
+	job.lock.Unlock()
+
+	job.cancel()
+}
+
+// Returns how long the job has been running, or how long it ran if it has terminated
+func (job *Job) Elapsed() time.Duration {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	if job.end.IsZero() {
+		return time.Since(job.Start)
+	}
+
+	return job.end.Sub(job.Start)
+}
+
+// Returns the job status: running, done, failed or killed
+func (job *Job) Status() string {
+	if !job.Done() {
+		return "running"
+	}
+
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	switch {
+	case job.killed:
+		return "killed"
+	case job.err != nil:
+		return "failed"
+	default:
+		return "done"
+	}
+}
+
+//
+// Execute the command line in the background, as a new job.
+// The job context is cancelled when the job is killed or the command loop terminates.
+//
+// The output of the command is captured and available from Job.Output and, if TeeJobOutput is set,
+// it's also written to the interpreter output while the job runs.
+//
+func (cmd *Cmd) StartJob(line string) *Job {
+	ctx, cancel := context.WithCancel(cmd.ctx)
+
+	output := new(syncBuffer)
+	s := streams{stdin: cmd.Stdin, stdout: output, stderr: output}
+
+	if cmd.TeeJobOutput {
+		s.stdout = io.MultiWriter(output, cmd.Stdout)
+		s.stderr = io.MultiWriter(output, cmd.Stderr)
+	}
+
+	cmd.jobsLock.Lock()
+	cmd.lastJobID++
+
+	job := &Job{
+		ID:     cmd.lastJobID,
+		Line:   line,
+		Start:  time.Now(),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		output: output,
+	}
+
+	if cmd.jobs == nil {
+		cmd.jobs = make(map[int]*Job)
+	}
+
+	cmd.jobs[job.ID] = job
+	cmd.jobsLock.Unlock()
+
+	go func() {
+		defer close(job.done)
+		defer cancel()
+
+		_, executed, err := cmd.dispatch(ctx, s, line, false)
+
+		job.lock.Lock()
+		job.end = time.Now()
+		job.err = err
+		killed := job.killed
+		job.lock.Unlock()
+
+		if err != nil && !killed {
+			cmd.reportError(s, executed, err)
+		}
+	}()
+
+	return job
+}
+
+// Returns the list of jobs, sorted by ID
+func (cmd *Cmd) Jobs() []*Job {
+	cmd.jobsLock.Lock()
+	defer cmd.jobsLock.Unlock()
+
+	jobs := make([]*Job, 0, len(cmd.jobs))
+	for _, job := range cmd.jobs {
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// Remove the terminated jobs (and their output) from the list of jobs
+func (cmd *Cmd) ClearJobs() {
+	cmd.jobsLock.Lock()
+	defer cmd.jobsLock.Unlock()
+
+	for id, job := range cmd.jobs {
+		if job.Done() {
+			delete(cmd.jobs, id)
+		}
+	}
+}
+
+// Returns the number of jobs still running
+func (cmd *Cmd) JobCount() (count int) {
+	for _, job := range cmd.Jobs() {
+		if !job.Done() {
+			count++
+		}
+	}
+
+	return
+}
+
+// find the job with the specified ID
+func (cmd *Cmd) findJob(id string) (*Job, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, "%"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid job id: %s", id)
+	}
+
+	cmd.jobsLock.Lock()
+	defer cmd.jobsLock.Unlock()
+
+	job, ok := cmd.jobs[n]
+	if !ok {
+		return nil, fmt.Errorf("no such job: %s", id)
+	}
+
+	return job, nil
+}
+
+func (cmd *Cmd) addJobCommands() {
+	cmd.Add(NewCommand("go",
+		SetHelp(`go cmd: asynchronous execution of cmd`),
+		SetArgs("cmd..."),
+		SetCmdE(cmd.Go)))
+
+	jobs := NewCommand("jobs",
+		SetHelp(`list running and terminated jobs`),
+		SetBoolFlag("clear", false, "remove the terminated jobs after listing them"),
+		SetCmdE(cmd.ListJobs))
+
+	jobs.AddSubCommand("output",
+		SetHelp(`display the output of the specified job`),
+		SetArgs("id"),
+		SetCmdE(cmd.JobOutput))
+
+	cmd.Add(jobs)
+
+	cmd.Add(NewCommand("wait",
+		SetHelp(`wait for the specified jobs, or all running jobs, to terminate`),
+		SetArgs("[id...]"),
+		SetCmdE(cmd.WaitJobs)))
+
+	cmd.Add(NewCommand("kill",
+		SetHelp(`cancel the specified job`),
+		SetArgs("id"),
+		SetCmdE(cmd.KillJob)))
+}
+
+//
+// Go command: execute the command line in the background (see StartJob).
+// The variables are expanded when the job starts, from the command line as typed.
+//
+func (cmd *Cmd) Go(command *Command, line string) error {
+	if command.Args()[0] == command.name {
+		return errors.New("Don't go go me!")
+	}
+
+	if len(command.rawParams) > 0 {
+		line = command.rawParams
+	}
+
+	job := cmd.StartJob(line)
+	fmt.Fprintf(command.Stdout(), "[%d] %s\n", job.ID, job.Line)
+	return nil
+}
+
+//
+// Jobs command: list the jobs with their status and elapsed time
+// (and remove the terminated jobs if -clear is specified)
+//
+func (cmd *Cmd) ListJobs(command *Command, line string) error {
+	tw := tabwriter.NewWriter(command.Stdout(), 0, 8, 2, ' ', 0)
+
+	for _, job := range cmd.Jobs() {
+		fmt.Fprintf(tw, "[%d]\t%s\t%v\t%s\n", job.ID, job.Status(), job.Elapsed().Round(time.Millisecond), job.Line)
+	}
+
+	if command.GetBoolFlag("clear") {
+		cmd.ClearJobs()
+	}
+
+	return tw.Flush()
+}
+
+//
+// Jobs output command: display the output of the specified job
+//
+func (cmd *Cmd) JobOutput(command *Command, line string) error {
+	job, err := cmd.findJob(command.Arg("id"))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(command.Stdout(), job.Output())
+	return err
+}
+
+//
+// Wait command: wait for the specified jobs, or all the jobs, to terminate.
+// Returns the first job error, if any.
+//
+func (cmd *Cmd) WaitJobs(command *Command, line string) (err error) {
+	var jobs []*Job
+
+	if len(command.Args()) == 0 {
+		for _, job := range cmd.Jobs() {
+			if !job.Done() {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+
+	for _, id := range command.Args() {
+		job, err := cmd.findJob(id)
+		if err != nil {
+			return err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	for _, job := range jobs {
+		select {
+		case <-job.done:
+		case <-command.Context().Done():
+			return command.Context().Err()
+		}
+
+		if jerr := job.Err(); jerr != nil && err == nil {
+			err = fmt.Errorf("job %d: %v", job.ID, jerr)
+		}
+	}
+
+	return
+}
+
+//
+// Kill command: cancel the specified job
+//
+func (cmd *Cmd) KillJob(command *Command, line string) error {
+	job, err := cmd.findJob(command.Arg("id"))
+	if err != nil {
+		return err
+	}
+
+	if job.Done() {
+		return fmt.Errorf("job %d has already terminated", job.ID)
+	}
+
+	job.Kill()
+	return nil
+}
+
+//
+// A buffer that can be written concurrently
+//
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
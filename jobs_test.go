@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func newJobsCmd(stdout, stderr *bytes.Buffer) *Cmd {
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: stdout, Stderr: stderr, EnableJobs: true}
+	commander.Init()
+
+	commander.Add(NewCommand("say", SetCmd(func(command *Command, line string) bool {
+		fmt.Fprintln(command.Stdout(), line)
+		return false
+	})))
+
+	commander.Add(NewCommand("fail", SetCmdE(func(*Command, string) error {
+		return errors.New("failed")
+	})))
+
+	// run until the job is killed
+	commander.Add(NewCommand("block", SetCmdE(func(command *Command, line string) error {
+		<-command.Context().Done()
+		return command.Context().Err()
+	})))
+
+	return commander
+}
+
+func TestJobs(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	commander := newJobsCmd(&stdout, &stderr)
+
+	steps := []struct {
+		line   string
+		output string
+		status int
+	}{
+		{"go say a", "[1] say a\n", StatusOK},
+		{"wait 1", "", StatusOK},
+		{"go fail", "[2] fail\n", StatusOK},
+		{"wait %2", "", StatusError},
+		{"go block", "[3] block\n", StatusOK},
+		{"kill 3", "", StatusOK},
+		{"wait 3", "", StatusError},
+		{"wait", "", StatusOK},
+		{"kill 3", "", StatusError},
+		{"kill 4", "", StatusError},
+		{"wait x", "", StatusError},
+		{"go go say a", "", StatusError},
+		{"go", "", StatusUsage},
+	}
+
+	for _, step := range steps {
+		stdout.Reset()
+		commander.OneCmd(step.line)
+
+		if stdout.String() != step.output || commander.ExitStatus() != step.status {
+			t.Errorf("%q: output %q, status %d, want %q, %d", step.line, stdout.String(), commander.ExitStatus(), step.output, step.status)
+		}
+	}
+
+	var statuses []string
+	for _, job := range commander.Jobs() {
+		statuses = append(statuses, fmt.Sprintf("%d %s %s", job.ID, job.Line, job.Status()))
+	}
+
+	if got, want := strings.Join(statuses, ", "), "1 say a done, 2 fail failed, 3 block killed"; got != want {
+		t.Errorf("jobs: %s, want %s", got, want)
+	}
+
+	if commander.JobCount() != 0 {
+		t.Errorf("running jobs: %d", commander.JobCount())
+	}
+
+	stdout.Reset()
+	commander.OneCmd("jobs -clear")
+
+	if lines := strings.Count(stdout.String(), "\n"); lines != 3 {
+		t.Errorf("jobs -clear listed %d jobs:\n%s", lines, stdout.String())
+	}
+
+	if jobs := commander.Jobs(); len(jobs) != 0 {
+		t.Errorf("jobs after -clear: %d", len(jobs))
+	}
+}
+
+func TestJobOutput(t *testing.T) {
+	tests := []struct {
+		line   string
+		tee    bool
+		output string // the job output
+		stdout string // the interpreter output
+		failed bool
+	}{
+		{"say a; say b", false, "a\nb\n", "", false},
+		{"say a; say b", true, "a\nb\n", "a\nb\n", false},
+		{"say a | say b", false, "b\n", "", false},
+		{"fail", false, "error: failed\n", "", true},
+		{"nope", false, "invalid command: nope\n", "", true},
+		{"nope", true, "invalid command: nope\n", "", true},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+
+		commander := newJobsCmd(&stdout, &stderr)
+		commander.TeeJobOutput = tt.tee
+
+		result := commander.StartJob(tt.line).Wait()
+
+		if result.Output != tt.output || stdout.String() != tt.stdout || (result.Err != nil) != tt.failed {
+			t.Errorf("%q (tee %v): output %q, stdout %q, error %v, want %q, %q, %v",
+				tt.line, tt.tee, result.Output, stdout.String(), result.Err, tt.output, tt.stdout, tt.failed)
+		}
+
+		if tt.tee && stderr.String()+stdout.String() != tt.output {
+			t.Errorf("%q (tee %v): interpreter output %q, want %q", tt.line, tt.tee, stderr.String()+stdout.String(), tt.output)
+		}
+
+		// the job output command
+		stdout.Reset()
+		commander.OneCmd("jobs output 1")
+
+		if stdout.String() != tt.output {
+			t.Errorf("%q: jobs output %q, want %q", tt.line, stdout.String(), tt.output)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+//
+// execute a command line without sequence operators, that can be a pipeline of commands
+// with the output redirected to a file.
+// The variables are expanded when each command is tokenized, so that their values are never parsed.
+//
+func (cmd *Cmd) dispatchPipeline(ctx context.Context, s streams, line string) (stop bool, err error) {
+	tokens, err := tokenize(line, cmd.varLookup(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	tokens, filename, appending, err := parseRedirect(tokens)
+	if err != nil {
+		return false, err
+	}
+
+	if len(filename) > 0 {
+		f, err := openRedirect(filename, appending)
+		if err != nil {
+			return false, err
+		}
+
+		defer f.Close()
+		s.stdout = f
+	}
+
+	stages, err := splitPipeline(line, tokens)
+	if err != nil {
+		return false, err
+	}
+
+	if len(stages) == 1 {
+		return cmd.runCommand(ctx, s, stages[0])
+	}
+
+	return cmd.runPipeline(ctx, s, stages)
+}
+
+//
+// Run the pipeline stages concurrently, connecting the output of each stage to the input of the next one.
+// A stage can be a registered command or, if EnableShell is set, an external program.
+//
+// Returns the error of the last stage that failed, if any.
+//
+func (cmd *Cmd) runPipeline(ctx context.Context, s streams, stages []string) (stop bool, err error) {
+	stops := make([]bool, len(stages))
+	errs := make([]error, len(stages))
+
+	var wg sync.WaitGroup
+
+	in := s.stdin
+
+	for i, stage := range stages {
+		ss := streams{stdin: in, stdout: s.stdout, stderr: s.stderr}
+
+		var pr *io.PipeReader
+		var pw *io.PipeWriter
+
+		if i < len(stages)-1 {
+			pr, pw = io.Pipe()
+			ss.stdout = pw
+		}
+
+		wg.Add(1)
+
+		go func(i int, stage string, ss streams, pw *io.PipeWriter) {
+			defer wg.Done()
+
+			stops[i], errs[i] = cmd.runStage(ctx, ss, stage)
+
+			if pw != nil {
+				// signal end of input to the next stage
+				pw.Close()
+			}
+
+			if r, ok := ss.stdin.(*io.PipeReader); ok && i > 0 {
+				// the previous stage can't write anymore
+				r.CloseWithError(io.ErrClosedPipe)
+			}
+		}(i, stage, ss, pw)
+
+		in = pr
+	}
+
+	wg.Wait()
+
+	for i := range stages {
+		stop = stop || stops[i]
+
+		if errs[i] != nil && (i == len(stages)-1 || !errors.Is(errs[i], io.ErrClosedPipe)) {
+			err = errs[i]
+		}
+	}
+
+	return
+}
+
+// run a pipeline stage
+func (cmd *Cmd) runStage(ctx context.Context, s streams, line string) (bool, error) {
+	if cmd.EnableShell && !strings.HasPrefix(line, "!") {
+		tokens, err := tokenize(line, cmd.varLookup(ctx))
+		if err != nil {
+			return false, err
+		}
+
+		if len(tokens) == 0 {
+			return cmd.runCommand(ctx, s, line)
+		}
+
+		if command, err := lookupCommand(cmd.Commands, tokens[0].value, cmd.EnableAbbreviations); command == nil && err == nil {
+			// external program
+			return false, cmd.shellExec(ctx, s, tokenValues(tokens))
+		}
+	}
+
+	return cmd.runCommand(ctx, s, line)
+}
+
+//
+// Split the tokenized line on the pipe operators.
+// Returns the text of each command in the pipeline.
+//
+func splitPipeline(line string, tokens []token) (stages []string, err error) {
+	start := 0
+
+	for i, t := range tokens {
+		if t.op && t.value == "|" {
+			if i == start {
+				return nil, errors.New("syntax error: empty command in pipeline")
+			}
+
+			stages = append(stages, tokensText(line, tokens[start:i]))
+			start = i + 1
+		}
+	}
+
+	if start == len(tokens) && len(stages) > 0 {
+		return nil, errors.New("syntax error: empty command in pipeline")
+	}
+
+	return append(stages, tokensText(line, tokens[start:])), nil
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPipeline(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		err  bool
+	}{
+		{"ls -l", []string{"ls -l"}, false},
+		{"ls -l | grep a  |wc", []string{"ls -l", "grep a", "wc"}, false},
+		{`echo "a|b" 'c|d' e\|f`, []string{`echo "a|b" 'c|d' e\|f`}, false},
+		{"a || b", []string{"a || b"}, false},
+		{"| a", nil, true},
+		{"a | | b", nil, true},
+		{"a |", nil, true},
+	}
+
+	for _, tt := range tests {
+		tokens, err := tokenize(tt.line, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stages, err := splitPipeline(tt.line, tokens)
+		if (err != nil) != tt.err || !reflect.DeepEqual(stages, tt.want) {
+			t.Errorf("splitPipeline(%q) = %q, %v, want %q", tt.line, stages, err, tt.want)
+		}
+	}
+}
+
+func TestPipelineUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr}
+	commander.Init()
+
+	commander.Add(NewCommand("count", SetCmd(func(command *Command, line string) bool {
+		data, _ := ioutil.ReadAll(command.Stdin())
+		fmt.Fprintln(command.Stdout(), strings.Count(string(data), "\n"))
+		return false
+	})))
+
+	filename := filepath.Join(t.TempDir(), "out")
+
+	tests := []struct {
+		line   string
+		stdout string
+	}{
+		{"nope | count", "0\n"},
+		{"nope > " + filename, ""},
+	}
+
+	for _, tt := range tests {
+		stdout.Reset()
+		stderr.Reset()
+
+		commander.OneCmd(tt.line)
+
+		if stdout.String() != tt.stdout || !strings.Contains(stderr.String(), "invalid command: nope") {
+			t.Errorf("%q: output %q, errors %q, want %q", tt.line, stdout.String(), stderr.String(), tt.stdout)
+		}
+	}
+
+	if data, err := ioutil.ReadFile(filename); err != nil || len(data) != 0 {
+		t.Errorf("redirected output %q, %v, want an empty file", data, err)
+	}
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//
+// This is the data available to the prompt templates (see Cmd.Prompt and Cmd.RightPrompt).
+//
+// The templates can also call:
+//
+//   {{var "name"}}          the value of the variable (or environment variable)
+//   {{color "red" .Status}} the text in the specified color (black, red, green, yellow, blue, magenta, cyan, white, gray or bold)
+//
+type PromptData struct {
+	// the exit status of the last command
+	Status int
+	// the error returned by the last command, if any
+	Err error
+	// how long the last command took to execute
+	Elapsed time.Duration
+	// the number of jobs running in the background
+	Jobs int
+}
+
+// ANSI escape codes for the prompt colors
+var promptColors = map[string]int{
+	"bold":    1,
+	"black":   30,
+	"red":     31,
+	"green":   32,
+	"yellow":  33,
+	"blue":    34,
+	"magenta": 35,
+	"cyan":    36,
+	"white":   37,
+	"gray":    90,
+}
+
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*[A-Za-z]")
+
+// Returns the text without the ANSI escape sequences
+func stripANSI(text string) string {
+	return ansiEscape.ReplaceAllString(text, "")
+}
+
+// Returns the text in the specified color
+func colorize(color string, text ...interface{}) (string, error) {
+	code, ok := promptColors[color]
+	if !ok {
+		return "", fmt.Errorf("invalid color: %s", color)
+	}
+
+	return fmt.Sprintf("\033[%dm%s\033[0m", code, fmt.Sprint(text...)), nil
+}
+
+//
+// Returns the data for the prompt templates
+//
+func (cmd *Cmd) promptData() PromptData {
+	jobs := cmd.JobCount()
+
+	cmd.statusLock.Lock()
+	defer cmd.statusLock.Unlock()
+
+	return PromptData{
+		Status:  cmd.exitStatus,
+		Err:     cmd.lastError,
+		Elapsed: cmd.lastElapsed,
+		Jobs:    jobs,
+	}
+}
+
+//
+// Execute the prompt template.
+// The prompt is returned as is if it's not a template or if the template fails.
+//
+func (cmd *Cmd) expandPrompt(prompt string) string {
+	if !strings.Contains(prompt, "{{") {
+		return prompt
+	}
+
+	t, ok := cmd.promptTemplates[prompt]
+	if !ok {
+		var err error
+
+		t, err = template.New("prompt").Funcs(template.FuncMap{
+			"var":   cmd.varValue,
+			"color": colorize,
+		}).Parse(prompt)
+
+		if err != nil {
+			fmt.Fprintln(cmd.Stderr, "invalid prompt:", err)
+			t = nil
+		}
+
+		if cmd.promptTemplates == nil {
+			cmd.promptTemplates = make(map[string]*template.Template)
+		}
+
+		cmd.promptTemplates[prompt] = t
+	}
+
+	if t == nil {
+		return prompt
+	}
+
+	var b bytes.Buffer
+
+	if err := t.Execute(&b, cmd.promptData()); err != nil {
+		fmt.Fprintln(cmd.Stderr, "invalid prompt:", err)
+		return prompt
+	}
+
+	return b.String()
+}
+
+//
+// Returns the prompt for the next command, from PromptFunc or the Prompt template.
+//
+// If the prompt has multiple lines, or there is a right-side prompt, all but the last line
+// are displayed here (with the right-side prompt on the line above the input line)
+// and the last line, without colors, is returned for the line editor.
+//
+func (cmd *Cmd) nextPrompt() string {
+	var prompt string
+
+	if cmd.PromptFunc != nil {
+		prompt = cmd.PromptFunc()
+	} else {
+		prompt = cmd.expandPrompt(cmd.Prompt)
+	}
+
+	header := ""
+	if i := strings.LastIndex(prompt, "\n"); i >= 0 {
+		header, prompt = prompt[:i+1], prompt[i+1:]
+	}
+
+	interactive := cmd.isInteractive()
+
+	right := ""
+	if interactive {
+		right = cmd.expandPrompt(cmd.RightPrompt)
+	}
+
+	if len(right) > 0 {
+		// the right prompt is displayed at the end of the line above the input line
+		header = strings.TrimSuffix(header, "\n")
+	}
+
+	if len(header) > 0 {
+		if interactive {
+			ColorizeString(header)
+		} else {
+			fmt.Fprint(cmd.Stdout, header)
+		}
+	}
+
+	if len(right) > 0 {
+		ColorizeString(RightJustifyText(right))
+		fmt.Fprintln(cmd.Stdout)
+	}
+
+	if cmd.readline != nil {
+		// the line editor doesn't accept control characters in the prompt
+		prompt = stripANSI(prompt)
+	}
+
+	return prompt
+}
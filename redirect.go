@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+//
+// Split a trailing output redirection ("> file" or ">> file") from the tokenized line.
+// Returns the command tokens, the file name (empty if there is no redirection) and
+// true if the output should be appended to the file.
+//
+func parseRedirect(tokens []token) (command []token, filename string, appending bool, err error) {
+	// a redirection at the start of the line is not a redirection (the command name starts with '>')
+	// and a redirection is only valid at the end of the line
+	for i := 1; i < len(tokens); i++ {
+		if t := tokens[i]; t.op && (t.value == ">" || t.value == ">>") {
+			if i != len(tokens)-2 || tokens[i+1].op {
+				return tokens, "", false, errors.New("syntax error: invalid output redirection")
+			}
+
+			return tokens[:i], tokens[i+1].value, t.value == ">>", nil
+		}
+	}
+
+	return tokens, "", false, nil
+}
+
+// open the file the output is redirected to
+func openRedirect(filename string, appending bool) (*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+
+	if appending {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	return os.OpenFile(filename, flags, 0666)
+}
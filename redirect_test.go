@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRedirect(t *testing.T) {
+	tests := []struct {
+		line      string
+		command   []string
+		filename  string
+		appending bool
+		err       bool
+	}{
+		{"ls -l", []string{"ls", "-l"}, "", false, false},
+		{"ls -l > out.txt", []string{"ls", "-l"}, "out.txt", false, false},
+		{"ls>>'my file'", []string{"ls"}, "my file", true, false},
+		{"ls | wc > out", []string{"ls", "|", "wc"}, "out", false, false},
+		{`echo "a > b" \>c`, []string{"echo", "a > b", ">c"}, "", false, false},
+		{"> out", []string{">", "out"}, "", false, false},
+		{"ls >", nil, "", false, true},
+		{"ls > a b", nil, "", false, true},
+		{"ls > | wc", nil, "", false, true},
+		{"echo a > b > c", nil, "", false, true},
+		{"echo a >> b c", nil, "", false, true},
+		{"> a > b", []string{">", "a"}, "b", false, false},
+	}
+
+	for _, tt := range tests {
+		tokens, err := tokenize(tt.line, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		command, filename, appending, err := parseRedirect(tokens)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseRedirect(%q): no error", tt.line)
+			}
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(tokenValues(command), tt.command) || filename != tt.filename || appending != tt.appending {
+			t.Errorf("parseRedirect(%q) = %q, %q, %v, %v, want %q, %q, %v",
+				tt.line, tokenValues(command), filename, appending, err, tt.command, tt.filename, tt.appending)
+		}
+	}
+}
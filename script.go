@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+//
+// This is returned by RunScript and RunFile when a command fails
+//
+type ScriptError struct {
+	// the script file name, if known
+	Name string
+	// the line number where the failing command starts
+	Line int
+	// the error returned by the command
+	Err error
+}
+
+func (e *ScriptError) Error() string {
+	if len(e.Name) > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.Name, e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+//
+// Execute the commands read from r, one per line, as if they were typed at the prompt.
+//
+// Empty lines and lines starting with '#' are ignored, and a line ending with '\'
+// or within quotes or brackets continues on the next one. Execution stops when a command returns true (stop) or fails,
+// in which case a *ScriptError with the line number of the failing command is returned.
+//
+func (cmd *Cmd) RunScript(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	var pending string
+	lineno, start := 0, 0
+
+	for scanner.Scan() {
+		lineno++
+		text := scanner.Text()
+
+		if len(pending) == 0 {
+			start = lineno
+
+			if strings.HasPrefix(strings.TrimSpace(text), "#") {
+				// comment
+				continue
+			}
+		}
+
+		line, sep, more := continuation(pending + text)
+		if more {
+			// continuation line
+			pending = line + sep
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		pending = ""
+
+		if stop, err := cmd.runScriptLine(line, start); stop || err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return &ScriptError{Line: lineno, Err: err}
+	}
+
+	// the last line ended with a continuation
+	_, err := cmd.runScriptLine(strings.TrimSpace(pending), start)
+	return err
+}
+
+func (cmd *Cmd) runScriptLine(line string, lineno int) (stop bool, err error) {
+	if len(line) == 0 {
+		return
+	}
+
+	if stop, err = cmd.runLine(line); err != nil {
+		err = &ScriptError{Line: lineno, Err: err}
+	}
+
+	return
+}
+
+//
+// Execute the commands in the specified file (see RunScript)
+//
+func (cmd *Cmd) RunFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	err = cmd.RunScript(f)
+	if serr, ok := err.(*ScriptError); ok {
+		serr.Name = filename
+	}
+
+	return err
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errFailed = errors.New("failed")
+
+func newScriptCmd(out *bytes.Buffer) *Cmd {
+	var stderr bytes.Buffer
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: out, Stderr: &stderr}
+	commander.Init()
+
+	commander.Add(NewCommand("say", SetCmd(func(command *Command, line string) bool {
+		fmt.Fprintln(command.Stdout(), line)
+		return false
+	})))
+
+	commander.Add(NewCommand("quit", SetCmd(func(*Command, string) bool {
+		return true
+	})))
+
+	commander.Add(NewCommand("fail", SetCmdE(func(*Command, string) error {
+		return errFailed
+	})))
+
+	return commander
+}
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		script string
+		output string
+		line   int // the line of the ScriptError, 0 if none
+	}{
+		{"", "", 0},
+		{"say a\nsay b\n", "a\nb\n", 0},
+		{"say a\nsay b", "a\nb\n", 0},
+		{"# comment\n\n  # indented comment\nsay a\n", "a\n", 0},
+		{"say a \\\n  b\nsay c\n", "a   b\nc\n", 0},
+		{"say 'a\nb'\nfail\n", "'a\nb'\n", 3},
+		{"say a\n\nfail\nsay b\n", "a\n", 3},
+		{"say a\nfail \\\n  x\n", "a\n", 2},
+		{"say a\nnope\nsay b\n", "a\n", 2},
+		{"say a\nquit\nsay b\n", "a\n", 0},
+		{"say a\\", "a\n", 0},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+
+		err := newScriptCmd(&out).RunScript(strings.NewReader(tt.script))
+
+		line := 0
+
+		var serr *ScriptError
+		if errors.As(err, &serr) {
+			line = serr.Line
+		} else if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.script, err)
+		}
+
+		if out.String() != tt.output || line != tt.line {
+			t.Errorf("%q: output %q, error line %d, want %q, %d", tt.script, out.String(), line, tt.output, tt.line)
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	var out bytes.Buffer
+
+	commander := newScriptCmd(&out)
+
+	filename := filepath.Join(t.TempDir(), "script")
+	if err := ioutil.WriteFile(filename, []byte("say a\nfail\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := commander.RunFile(filename)
+
+	var serr *ScriptError
+	if !errors.As(err, &serr) || serr.Name != filename || serr.Line != 2 || serr.Err != errFailed {
+		t.Errorf("RunFile: %v", err)
+	}
+
+	if err := commander.RunFile(filename + ".missing"); err == nil {
+		t.Error("RunFile: no error for a missing file")
+	}
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// This is implemented by the structs passed to NewCommandFromStruct.
+// Run is called with the struct fields populated from the command flags and arguments.
+//
+type Runner interface {
+	Run(command *Command) error
+}
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	stringSliceType = reflect.TypeOf([]string(nil))
+)
+
+//
+// Create a command from a pointer to a struct implementing Runner.
+//
+// The struct fields tagged with `flag:"name"` are registered as flags, using the
+// `default:"value"` and `help:"text"` tags for the default value and the flag description
+// (and `enum:"a,b,c"` to restrict a string flag to a set of values).
+// Supported types are string, bool, int, float64, time.Duration and []string (a repeatable flag).
+//
+// The fields tagged with `arg:"name"` are set from the positional arguments, in order,
+// using the same syntax as SetArgs (i.e. `arg:"[name]"` for an optional argument
+// and `arg:"name..."` for a variadic one, that must be a []string).
+// The tagged fields must be exported.
+//
+// Every time the command is invoked, Run is called on a copy of the struct with the
+// fields populated, so that the struct pointed by ptr is only used as a template.
+//
+func NewCommandFromStruct(name string, ptr interface{}, opts ...Option) *Command {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("%s: expected a pointer to a struct, got %T", name, ptr))
+	}
+
+	if _, ok := ptr.(Runner); !ok {
+		panic(fmt.Sprintf("%s: %T doesn't implement Runner", name, ptr))
+	}
+
+	t := v.Elem().Type()
+
+	var structOpts []Option
+	var args []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		_, isFlag := field.Tag.Lookup("flag")
+		_, isArg := field.Tag.Lookup("arg")
+
+		if (isFlag || isArg) && !field.IsExported() {
+			// the field couldn't be set when the command is invoked
+			panic(fmt.Sprintf("%s: field %s is not exported", name, field.Name))
+		}
+
+		if fname, ok := field.Tag.Lookup("flag"); ok {
+			structOpts = append(structOpts, structFlag(name, field, fname))
+		} else if arg, ok := field.Tag.Lookup("arg"); ok {
+			variadic := strings.HasSuffix(strings.TrimSuffix(arg, "]"), "...")
+
+			if (variadic && field.Type != stringSliceType) || (!variadic && field.Type.Kind() != reflect.String) {
+				panic(fmt.Sprintf("%s: invalid type %v for argument %q", name, field.Type, arg))
+			}
+
+			args = append(args, arg)
+		}
+	}
+
+	if len(args) > 0 {
+		structOpts = append(structOpts, SetArgs(args...))
+	}
+
+	structOpts = append(structOpts, SetCmdE(func(command *Command, line string) error {
+		target := reflect.New(t)
+		target.Elem().Set(v.Elem())
+
+		if err := command.populate(target.Elem()); err != nil {
+			return err
+		}
+
+		return target.Interface().(Runner).Run(command)
+	}))
+
+	return NewCommand(name, append(structOpts, opts...)...)
+}
+
+// return the option to register the flag for the struct field
+func structFlag(name string, field reflect.StructField, fname string) Option {
+	def := field.Tag.Get("default")
+	help := field.Tag.Get("help")
+
+	invalid := func(err error) {
+		panic(fmt.Sprintf("%s: invalid default value %q for flag -%s: %v", name, def, fname, err))
+	}
+
+	switch {
+	case field.Type == durationType:
+		var d time.Duration
+		if len(def) > 0 {
+			var err error
+			if d, err = time.ParseDuration(def); err != nil {
+				invalid(err)
+			}
+		}
+
+		return SetDurationFlag(fname, d, help)
+
+	case field.Type == stringSliceType:
+		var values []string
+		if len(def) > 0 {
+			values = strings.Split(def, ",")
+		}
+
+		return SetStringSliceFlag(fname, values, help)
+	}
+
+	switch field.Type.Kind() {
+	case reflect.String:
+		if enum, ok := field.Tag.Lookup("enum"); ok {
+			return SetEnumFlag(fname, def, strings.Split(enum, ","), help)
+		}
+
+		return SetFlag(fname, def, help)
+
+	case reflect.Bool:
+		var b bool
+		if len(def) > 0 {
+			var err error
+			if b, err = strconv.ParseBool(def); err != nil {
+				invalid(err)
+			}
+		}
+
+		return SetBoolFlag(fname, b, help)
+
+	case reflect.Int:
+		var n int
+		if len(def) > 0 {
+			var err error
+			if n, err = strconv.Atoi(def); err != nil {
+				invalid(err)
+			}
+		}
+
+		return SetIntFlag(fname, n, help)
+
+	case reflect.Float64:
+		var f float64
+		if len(def) > 0 {
+			var err error
+			if f, err = strconv.ParseFloat(def, 64); err != nil {
+				invalid(err)
+			}
+		}
+
+		return SetFloatFlag(fname, f, help)
+	}
+
+	panic(fmt.Sprintf("%s: unsupported type %v for flag -%s", name, field.Type, fname))
+}
+
+// set the struct fields from the command flags and arguments
+func (command *Command) populate(v reflect.Value) error {
+	t := v.Type()
+	nargs := 0
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+
+		if fname, ok := field.Tag.Lookup("flag"); ok {
+			var value interface{}
+			var err error
+
+			switch {
+			case field.Type == durationType:
+				value, err = command.GetDurationFlag(fname)
+			case field.Type == stringSliceType:
+				value, err = command.GetStringSliceFlag(fname)
+			case field.Type.Kind() == reflect.String:
+				value = command.GetFlag(fname)
+			case field.Type.Kind() == reflect.Bool:
+				value = command.GetBoolFlag(fname)
+			case field.Type.Kind() == reflect.Int:
+				value, err = command.GetIntFlag(fname)
+			case field.Type.Kind() == reflect.Float64:
+				value, err = command.GetFloatFlag(fname)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			fv.Set(reflect.ValueOf(value).Convert(field.Type))
+		} else if _, ok := field.Tag.Lookup("arg"); ok {
+			if nargs < len(command.args) {
+				if field.Type == stringSliceType {
+					fv.Set(reflect.ValueOf(append([]string(nil), command.args[nargs:]...)))
+				} else {
+					fv.SetString(command.args[nargs])
+				}
+			}
+
+			nargs++
+		}
+	}
+
+	return nil
+}
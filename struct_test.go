@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+type structRunner struct{}
+
+func (structRunner) Run(command *Command) error { return nil }
+
+type unexportedFlag struct {
+	structRunner
+	n int `flag:"n"`
+}
+
+type unexportedArg struct {
+	structRunner
+	src string `arg:"src"`
+}
+
+type invalidFlagType struct {
+	structRunner
+	N uint `flag:"n"`
+}
+
+type invalidDefault struct {
+	structRunner
+	N int `flag:"n" default:"x"`
+}
+
+type invalidArgType struct {
+	structRunner
+	Src int `arg:"src"`
+}
+
+type invalidVariadic struct {
+	structRunner
+	Files string `arg:"files..."`
+}
+
+func TestNewCommandFromStructPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		ptr  interface{}
+	}{
+		{"not a pointer", structRunner{}},
+		{"not a runner", &struct{ N int }{}},
+		{"unexported flag", &unexportedFlag{}},
+		{"unexported arg", &unexportedArg{}},
+		{"invalid flag type", &invalidFlagType{}},
+		{"invalid default", &invalidDefault{}},
+		{"invalid arg type", &invalidArgType{}},
+		{"invalid variadic", &invalidVariadic{}},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: no panic", tt.name)
+				}
+			}()
+
+			NewCommandFromStruct("test", tt.ptr)
+		}()
+	}
+}
+
+type copyCommand struct {
+	Recursive bool          `flag:"r" help:"copy the directories"`
+	Count     int           `flag:"n" default:"3"`
+	Ratio     float64       `flag:"ratio" default:"0.5"`
+	Timeout   time.Duration `flag:"t" default:"2s"`
+	Format    string        `flag:"format" default:"json" enum:"json,yaml"`
+	Tags      []string      `flag:"tag" default:"a,b"`
+	Src       string        `arg:"src"`
+	Dst       []string      `arg:"[dst...]"`
+	Shared    string
+
+	result *string
+}
+
+func (c *copyCommand) Run(command *Command) error {
+	*c.result = fmt.Sprintf("%v %v %v %v %v %v %v %v %v", c.Recursive, c.Count, c.Ratio, c.Timeout, c.Format, c.Tags, c.Src, c.Dst, c.Shared)
+	return nil
+}
+
+func TestNewCommandFromStruct(t *testing.T) {
+	var got string
+
+	template := &copyCommand{Shared: "shared", result: &got}
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}}
+	commander.Init()
+	commander.Add(NewCommandFromStruct("cp", template))
+
+	tests := []struct {
+		line   string
+		want   string
+		status int
+	}{
+		{"cp a", "false 3 0.5 2s json [a b] a [] shared", StatusOK},
+		{"cp -r -n 5 -ratio 1.5 -t 1m a b c", "true 5 1.5 1m0s json [a b] a [b c] shared", StatusOK},
+		{"cp -format yaml -tag x -tag y a", "false 3 0.5 2s yaml [x y] a [] shared", StatusOK},
+		{"cp", "", StatusUsage},
+		{"cp -n x a", "", StatusUsage},
+		{"cp -format xml a", "", StatusUsage},
+	}
+
+	for _, tt := range tests {
+		got = ""
+		commander.OneCmd(tt.line)
+
+		if got != tt.want || commander.ExitStatus() != tt.status {
+			t.Errorf("%q: %q, status %d, want %q, %d", tt.line, got, commander.ExitStatus(), tt.want, tt.status)
+		}
+	}
+
+	// the template is not modified
+	if template.Count != 0 || template.Src != "" || template.Tags != nil {
+		t.Errorf("template modified: %+v", *template)
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// the default maximum edit distance for the "Did you mean" suggestions
+const defaultSuggestDistance = 2
+
+//
+// Returns the command names, aliases or sub command paths (with the same number of words)
+// closest to the unknown command, if they are within the maximum edit distance
+//
+func (cmd *Cmd) suggest(words []string) []string {
+	maxDistance := cmd.SuggestDistance
+	if maxDistance < 0 || len(words) == 0 {
+		return nil
+	}
+
+	if maxDistance == 0 {
+		maxDistance = defaultSuggestDistance
+	}
+
+	// the sub commands of the matching command are the only candidates for the last word
+	commands := cmd.Commands
+	prefix := ""
+
+	for _, word := range words[:len(words)-1] {
+		c, ok := commands[word]
+		if !ok {
+			return nil
+		}
+
+		commands = c.subCommands
+		prefix += word + " "
+	}
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+
+	var suggestions []suggestion
+
+	target := words[len(words)-1]
+
+	for name, c := range commands {
+		if c.hidden {
+			continue
+		}
+
+		if d := editDistance(target, name); d <= maxDistance {
+			suggestions = append(suggestions, suggestion{prefix + name, d})
+		}
+	}
+
+	closest := maxDistance
+	for _, s := range suggestions {
+		if s.distance < closest {
+			closest = s.distance
+		}
+	}
+
+	var names []string
+
+	for _, s := range suggestions {
+		if s.distance == closest {
+			names = append(names, s.name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// print the suggestions for the unknown command, if any
+func (cmd *Cmd) printSuggestions(w io.Writer, words []string) {
+	if suggestions := cmd.suggest(words); len(suggestions) > 0 {
+		fmt.Fprintf(w, "Did you mean: %s?\n", strings.Join(suggestions, ", "))
+	}
+}
+
+//
+// Returns the edit distance between a and b: the number of characters inserted, deleted or replaced,
+// where swapping two adjacent characters counts as a single edit
+//
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -18,7 +18,7 @@ func RightJustifyText(text string) string {
 	_, cols := size()
 
 	
-	col := cols - (len(text) + textPadding)
+	col := cols - (len(stripANSI(text)) + textPadding)
 	
 	if cols > 0 {
 		
@@ -38,6 +38,11 @@ func size()(rows,cols int) {
 	sz := string(out)
 	size := strings.Split(sz," ")
 
+	if len(size) < 2 {
+		// not a terminal
+		return 0, 0
+	}
+
 	var err error
 	
 	rows, err = strconv.Atoi(strings.TrimSpace(size[0]))
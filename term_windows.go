@@ -11,7 +11,7 @@ func RightJustifyText(text string) string {
 
 	_, cols, c_row, _ := size()
 
-	col := cols - (len(text) + textPadding)
+	col := cols - (len(stripANSI(text)) + textPadding)
 
 	handle, _ := syscall.GetStdHandle(syscall.STD_OUTPUT_HANDLE)
 
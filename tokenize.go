@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//
+// A word or an operator in a command line
+//
+type token struct {
+	// the word, with the quotes and escapes removed and the variables expanded, or the operator
+	value string
+	// the position of the token in the line
+	start, end int
+	// true if the token is an operator
+	op bool
+}
+
+//
+// A variable reference in a command line, and its value
+//
+type varRef struct {
+	start, end int
+	value      string
+}
+
+// the command line operators, longest first
+var operators = []string{"||", "&&", ">>", "|", ";", ">"}
+
+//
+// Split the line into words and operators, with the same quoting rules of a POSIX shell:
+//
+//   - words are separated by white space and by the operators (|, ||, &&, ;, > and >>)
+//   - characters within single quotes are taken literally
+//   - characters within double quotes are taken literally, except for \", \\, \$ and the variables
+//   - outside of quotes, a backslash takes the next character literally
+//
+// If lookup is not nil, the variables ($NAME, ${NAME} and $?) are replaced with their value
+// (outside of single quotes) and the value is never split or parsed.
+// A word that is empty after the expansion is dropped, unless it contains quotes (i.e. "$NAME").
+//
+// If the line ends within quotes, the tokens are returned with ErrUnterminatedQuote.
+//
+func tokenize(line string, lookup func(string) string) ([]token, error) {
+	tokens, _, err := scanLine(line, lookup)
+	return tokens, err
+}
+
+// Returns the token values
+func tokenValues(tokens []token) []string {
+	values := make([]string, len(tokens))
+
+	for i, t := range tokens {
+		values[i] = t.value
+	}
+
+	return values
+}
+
+// Returns the part of line covered by the tokens
+func tokensText(line string, tokens []token) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	return line[tokens[0].start:tokens[len(tokens)-1].end]
+}
+
+// Returns the operator at the start of s, or an empty string
+func operatorAt(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+
+	return ""
+}
+
+//
+// Split the line into tokens (see tokenize), also returning the variable references that were expanded
+//
+func scanLine(line string, lookup func(string) string) (tokens []token, refs []varRef, err error) {
+	var word strings.Builder
+
+	inWord := false
+	quoted := false
+	start := 0
+	quote := byte(0)
+
+	begin := func(i int) {
+		if !inWord {
+			inWord = true
+			start = i
+		}
+	}
+
+	flush := func(end int) {
+		if inWord && (quoted || word.Len() > 0) {
+			tokens = append(tokens, token{value: word.String(), start: start, end: end})
+		}
+
+		word.Reset()
+		inWord = false
+		quoted = false
+	}
+
+	// expand the variable at position i and return the number of bytes consumed
+	expand := func(i int) (int, error) {
+		name, n, err := varReference(line[i:])
+		if err != nil || n == 0 {
+			return n, err
+		}
+
+		value := lookup(name)
+		word.WriteString(value)
+		refs = append(refs, varRef{start: i, end: i + n, value: value})
+		return n, nil
+	}
+
+	for i := 0; i < len(line); {
+		ch := line[i]
+
+		if ch == '$' && quote != '\'' && lookup != nil {
+			begin(i)
+
+			n, err := expand(i)
+			if err != nil {
+				return tokens, refs, err
+			}
+
+			if n > 0 {
+				i += n
+				continue
+			}
+		}
+
+		switch {
+		case quote == '\'':
+			if ch == quote {
+				quote = 0
+			} else {
+				word.WriteByte(ch)
+			}
+
+			i++
+
+		case quote == '"':
+			if ch == quote {
+				quote = 0
+				i++
+			} else if ch == '\\' && i+1 < len(line) && strings.IndexByte(`"\$`, line[i+1]) >= 0 {
+				word.WriteByte(line[i+1])
+				i += 2
+			} else {
+				word.WriteByte(ch)
+				i++
+			}
+
+		case ch == '\\':
+			begin(i)
+			quoted = true
+
+			if i+1 < len(line) {
+				_, size := utf8.DecodeRuneInString(line[i+1:])
+				word.WriteString(line[i+1 : i+1+size])
+				i += 1 + size
+			} else {
+				// a trailing backslash is taken literally
+				word.WriteByte(ch)
+				i++
+			}
+
+		case ch == '\'' || ch == '"':
+			begin(i)
+			quoted = true
+			quote = ch
+			i++
+
+		default:
+			r, size := utf8.DecodeRuneInString(line[i:])
+
+			if unicode.IsSpace(r) {
+				flush(i)
+			} else if op := operatorAt(line[i:]); len(op) > 0 {
+				flush(i)
+				tokens = append(tokens, token{value: op, start: i, end: i + len(op), op: true})
+				size = len(op)
+			} else {
+				begin(i)
+				word.WriteString(line[i : i+size])
+			}
+
+			i += size
+		}
+	}
+
+	flush(len(line))
+
+	if quote != 0 {
+		err = ErrUnterminatedQuote
+	}
+
+	return
+}
+
+//
+// Returns a word quoted so that the tokenizer returns it unchanged
+// (the word is returned as is if it doesn't contain any special character)
+//
+func quoteWord(word string) string {
+	if len(word) > 0 && !strings.ContainsAny(word, " \t\n\r'\"\\$|&;>") {
+		return word
+	}
+
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
+
+//
+// Check if the command continues on the next input line: the text ends with a backslash
+// or within quotes, or it has unbalanced brackets.
+//
+// Returns the text (without the trailing backslash) and the separator to use
+// when joining it with the next line.
+//
+func continuation(text string) (line, sep string, more bool) {
+	quote := byte(0)
+	depth := 0
+	escaped := false
+
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '\'':
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\\':
+			escaped = true
+		case quote == '"':
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '(' || ch == '[' || ch == '{':
+			depth++
+		case ch == ')' || ch == ']' || ch == '}':
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+
+	switch {
+	case quote != 0:
+		// the newline is part of the quoted text
+		return text, "\n", true
+	case escaped:
+		return text[:len(text)-1], "", true
+	case depth > 0:
+		return text, "\n", true
+	}
+
+	return text, "", false
+}
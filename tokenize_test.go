@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	vars := map[string]string{"A": "a b", "E": "", "S": "x;y"}
+	lookup := func(name string) string { return vars[name] }
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{}},
+		{"  ls  -l   foo ", []string{"ls", "-l", "foo"}},
+		{`echo 'a  b' "c d" e\ f`, []string{"echo", "a  b", "c d", "e f"}},
+		{`echo 'a\b' "a\"b\\c\d"`, []string{"echo", `a\b`, `a"b\c\d`}},
+		{`echo a'b'"c"d`, []string{"echo", "abcd"}},
+		{"echo '' \"\"", []string{"echo", "", ""}},
+		{"a|b||c&&d;e>f>>g", []string{"a", "|", "b", "||", "c", "&&", "d", ";", "e", ">", "f", ">>", "g"}},
+		{`echo 'a|b' "c;d" e\>f`, []string{"echo", "a|b", "c;d", "e>f"}},
+		{"echo $A ${A}x", []string{"echo", "a b", "a bx"}},
+		{"echo '$A' \"$A\" \\$A", []string{"echo", "$A", "a b", "$A"}},
+		{"echo $S", []string{"echo", "x;y"}},
+		{"echo $E x", []string{"echo", "x"}},
+		{`echo "$E" x`, []string{"echo", "", "x"}},
+		{"echo $", []string{"echo", "$"}},
+	}
+
+	for _, tt := range tests {
+		tokens, err := tokenize(tt.line, lookup)
+		if err != nil {
+			t.Errorf("tokenize(%q): %v", tt.line, err)
+			continue
+		}
+
+		if got := tokenValues(tokens); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	line := `echo "a b" | wc`
+
+	tokens, err := tokenize(line, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := tokensText(line, tokens[1:]); got != `"a b" | wc` {
+		t.Errorf("tokensText = %q", got)
+	}
+
+	if !tokens[2].op || tokens[1].op {
+		t.Errorf("op = %v, %v", tokens[1].op, tokens[2].op)
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	for _, line := range []string{`echo "a b`, "echo 'a", `echo "a\"`} {
+		if _, err := tokenize(line, nil); err != ErrUnterminatedQuote {
+			t.Errorf("tokenize(%q): %v, want %v", line, err, ErrUnterminatedQuote)
+		}
+	}
+}
+
+func TestQuoteWord(t *testing.T) {
+	for _, word := range []string{"abc", "", "a b", "it's", `a"b\c`, "$A", "a;b|c", "a\nb"} {
+		tokens, err := tokenize(quoteWord(word), func(string) string { return "X" })
+		if err != nil || len(tokens) != 1 || tokens[0].value != word {
+			t.Errorf("quoteWord(%q) = %q: %v %v", word, quoteWord(word), tokens, err)
+		}
+	}
+}
+
+func TestContinuation(t *testing.T) {
+	tests := []struct {
+		text, line, sep string
+		more            bool
+	}{
+		{"echo a", "echo a", "", false},
+		{`echo a \`, "echo a ", "", true},
+		{`echo a\\`, `echo a\\`, "", false},
+		{`echo "a`, `echo "a`, "\n", true},
+		{`echo 'a\`, `echo 'a\`, "\n", true},
+		{`echo "a\"`, `echo "a\"`, "\n", true},
+		{`echo "a" 'b'`, `echo "a" 'b'`, "", false},
+		{"func {", "func {", "\n", true},
+		{"f(a, [b]", "f(a, [b]", "\n", true},
+		{"f(a) ]", "f(a) ]", "", false},
+		{`echo "("`, `echo "("`, "", false},
+		{`echo \(`, `echo \(`, "", false},
+	}
+
+	for _, tt := range tests {
+		line, sep, more := continuation(tt.text)
+		if line != tt.line || sep != tt.sep || more != tt.more {
+			t.Errorf("continuation(%q) = %q, %q, %v, want %q, %q, %v",
+				tt.text, line, sep, more, tt.line, tt.sep, tt.more)
+		}
+	}
+}
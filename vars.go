@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Set the value of an interpreter variable
+func (cmd *Cmd) SetVar(name, value string) {
+	cmd.varsLock.Lock()
+	defer cmd.varsLock.Unlock()
+
+	if cmd.vars == nil {
+		cmd.vars = make(map[string]string)
+	}
+
+	cmd.vars[name] = value
+}
+
+// Returns the value of an interpreter variable, or an empty string if it's not set
+func (cmd *Cmd) GetVar(name string) string {
+	value, _ := cmd.lookupVar(name)
+	return value
+}
+
+// Remove an interpreter variable
+func (cmd *Cmd) UnsetVar(name string) {
+	cmd.varsLock.Lock()
+	defer cmd.varsLock.Unlock()
+
+	delete(cmd.vars, name)
+}
+
+func (cmd *Cmd) lookupVar(name string) (string, bool) {
+	cmd.varsLock.Lock()
+	defer cmd.varsLock.Unlock()
+
+	value, ok := cmd.vars[name]
+	return value, ok
+}
+
+//
+// Returns the value of a variable, as expanded in a command line:
+// the interpreter variable if set, or the environment variable with the same name.
+// The special variable "?" is the exit status of the last command.
+//
+func (cmd *Cmd) varValue(name string) string {
+	if name == "?" {
+		return strconv.Itoa(cmd.ExitStatus())
+	}
+
+	if value, ok := cmd.lookupVar(name); ok {
+		return value
+	}
+
+	return os.Getenv(name)
+}
+
+// the context key of the exit status of the previous command in a background sequence
+type statusKey struct{}
+
+//
+// Returns the function to look up the variables in the command lines executed with ctx:
+// in a background sequence, $? is the exit status of the previous command in the sequence.
+//
+func (cmd *Cmd) varLookup(ctx context.Context) func(string) string {
+	status, ok := ctx.Value(statusKey{}).(*int)
+	if !ok {
+		return cmd.varValue
+	}
+
+	return func(name string) string {
+		if name == "?" {
+			return strconv.Itoa(*status)
+		}
+
+		return cmd.varValue(name)
+	}
+}
+
+func isVarChar(ch rune, first bool) bool {
+	return ch == '_' || unicode.IsLetter(ch) || (!first && unicode.IsDigit(ch))
+}
+
+func isVarName(name string) bool {
+	for i, ch := range name {
+		if !isVarChar(ch, i == 0) {
+			return false
+		}
+	}
+
+	return len(name) > 0
+}
+
+//
+// Parse the variable reference ($NAME, ${NAME} or $?) at the start of s.
+// Returns the variable name and the length of the reference, or 0 if s doesn't start with a reference.
+//
+func varReference(s string) (name string, n int, err error) {
+	if len(s) < 2 || s[0] != '$' {
+		return "", 0, nil
+	}
+
+	switch {
+	case s[1] == '?':
+		return "?", 2, nil
+
+	case s[1] == '{':
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", 0, errors.New("bad substitution: " + s)
+		}
+
+		if name = s[2:end]; !isVarName(name) {
+			return "", 0, errors.New("bad substitution: " + s[:end+1])
+		}
+
+		return name, end + 1, nil
+	}
+
+	end := 1
+	for end < len(s) {
+		ch, size := utf8.DecodeRuneInString(s[end:])
+		if !isVarChar(ch, end == 1) {
+			break
+		}
+
+		end += size
+	}
+
+	if end == 1 {
+		// a literal $
+		return "", 0, nil
+	}
+
+	return s[1:end], end, nil
+}
+
+//
+// Replace $NAME and ${NAME} in line with the variable values (from lookup).
+// Variables are not expanded within single quotes or when the $ is escaped,
+// and the quotes and escapes are left in the line.
+//
+func (cmd *Cmd) expandVars(line string, lookup func(string) string) (string, error) {
+	if !strings.ContainsRune(line, '$') {
+		return line, nil
+	}
+
+	_, refs, err := scanLine(line, lookup)
+	if err != nil && !errors.Is(err, ErrUnterminatedQuote) {
+		return "", err
+	}
+
+	var result strings.Builder
+
+	last := 0
+
+	for _, ref := range refs {
+		result.WriteString(line[last:ref.start])
+		result.WriteString(ref.value)
+		last = ref.end
+	}
+
+	result.WriteString(line[last:])
+	return result.String(), nil
+}
+
+func (cmd *Cmd) addVarCommands() {
+	cmd.Add(NewCommand("set",
+		SetHelp(`set name value: set a variable, expanded as $name or ${name}`),
+		SetArgs("name", "[value...]"),
+		SetCmdE(cmd.SetVarCommand)))
+
+	cmd.Add(NewCommand("unset",
+		SetHelp(`remove the specified variables`),
+		SetArgs("name..."),
+		SetCmdE(cmd.UnsetVarCommand)))
+
+	cmd.Add(NewCommand("vars",
+		SetHelp(`list the variables`),
+		SetCmdE(cmd.ListVars)))
+}
+
+//
+// Set command: set a variable, as "set name value" or "set name=value"
+//
+func (cmd *Cmd) SetVarCommand(command *Command, line string) error {
+	args := command.Args()
+	name, value := args[0], strings.Join(args[1:], " ")
+
+	if i := strings.Index(name, "="); i >= 0 && len(args) == 1 {
+		name, value = name[:i], name[i+1:]
+	}
+
+	if !isVarName(name) {
+		return fmt.Errorf("invalid variable name: %s", name)
+	}
+
+	cmd.SetVar(name, value)
+	return nil
+}
+
+//
+// Unset command: remove the specified variables
+//
+func (cmd *Cmd) UnsetVarCommand(command *Command, line string) error {
+	for _, name := range command.Args() {
+		cmd.UnsetVar(name)
+	}
+
+	return nil
+}
+
+//
+// Vars command: list the variables
+//
+func (cmd *Cmd) ListVars(command *Command, line string) error {
+	// copy the variables, so that the lock is not held while writing the output
+	// (the output may be a pipe to a command that reads or sets the variables)
+	cmd.varsLock.Lock()
+
+	names := make([]string, 0, len(cmd.vars))
+	values := make(map[string]string, len(cmd.vars))
+
+	for name, value := range cmd.vars {
+		names = append(names, name)
+		values[name] = value
+	}
+
+	cmd.varsLock.Unlock()
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(command.Stdout(), "%s=%s\n", name, values[name])
+	}
+
+	return nil
+}
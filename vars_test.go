@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListVarsPipe(t *testing.T) {
+	var out bytes.Buffer
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	commander.Init()
+
+	// read one line, then set a variable while the previous stage is still writing
+	commander.Add(NewCommand("first", SetCmd(func(command *Command, line string) bool {
+		first, _ := bufio.NewReader(command.Stdin()).ReadString('\n')
+		commander.SetVar("first", strings.TrimSpace(first))
+		return false
+	})))
+
+	commander.SetVar("a", "1")
+	commander.SetVar("b", "2")
+	commander.SetVar("c", "3")
+
+	done := make(chan struct{})
+
+	go func() {
+		commander.OneCmd("vars | first")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("vars | first doesn't terminate")
+	}
+
+	if got := commander.GetVar("first"); got != "a=1" {
+		t.Errorf("first = %q, want %q", got, "a=1")
+	}
+}
+
+func TestVars(t *testing.T) {
+	var out bytes.Buffer
+
+	commander := &Cmd{Stdin: strings.NewReader(""), Stdout: &out, Stderr: &out}
+	commander.Init()
+
+	commander.Add(NewCommand("say", SetCmd(func(command *Command, line string) bool {
+		fmt.Fprintf(command.Stdout(), "%s %q\n", line, command.Args())
+		return false
+	})))
+
+	os.Setenv("CMD_TEST_ENV", "env")
+	defer os.Unsetenv("CMD_TEST_ENV")
+
+	steps := []struct {
+		line   string
+		output string
+	}{
+		{"set name world", ""},
+		{"say hello $name", `hello world ["hello" "world"]` + "\n"},
+		{"say ${name}s $nope.", `worlds . ["worlds" "."]` + "\n"},
+		{`say '$name' "$name" \$name`, `'$name' "world" \$name ["$name" "world" "$name"]` + "\n"},
+		{"set x=a b", "invalid variable name: x=a\n"},
+		{"set spaced a  b", ""},
+		{"say $spaced", `a b ["a b"]` + "\n"},
+		{`say "$spaced"`, `"a b" ["a b"]` + "\n"},
+		{"set semi=a;say injected", `injected ["injected"]` + "\n"},
+		{"say $semi", `a ["a"]` + "\n"},
+		{"set semi 'x;say injected'", ""},
+		{"say $semi", `x;say injected ["x;say injected"]` + "\n"},
+		{"say $CMD_TEST_ENV", `env ["env"]` + "\n"},
+		{"set CMD_TEST_ENV var", ""},
+		{"say $CMD_TEST_ENV", `var ["var"]` + "\n"},
+		{"unset name CMD_TEST_ENV", ""},
+		{"say $name $CMD_TEST_ENV", `env ["env"]` + "\n"},
+		{"set 1x y", "invalid variable name: 1x\n"},
+		{"say ${1x}", "bad substitution: ${1x}\n"},
+		{"vars", "semi=x;say injected\nspaced=a b\n"},
+	}
+
+	for _, step := range steps {
+		out.Reset()
+		commander.OneCmd(step.line)
+
+		if got := strings.TrimPrefix(out.String(), "error: "); got != step.output {
+			t.Errorf("%q: %q, want %q", step.line, got, step.output)
+		}
+	}
+}